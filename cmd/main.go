@@ -1,60 +1,67 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
 	"time"
 
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/config"
 	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/mail"
 	"supermarket-catalogue/internal/middleware"
+	"supermarket-catalogue/internal/oidc"
 	"supermarket-catalogue/internal/repository"
+	"supermarket-catalogue/internal/repository/memory"
 
-	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 func main() {
-	err := repository.Init()
-	if err != nil {
-		log.Fatal("Database initialization failed:", err)
-	}
-	r := mux.NewRouter()
-
-	r.Use(middleware.CORSMiddleware)
-	r.Use(middleware.LoggingMiddleware)
-
-	r.HandleFunc("/register", handlers.RegisterHandler).Methods("POST")
-	r.HandleFunc("/login", handlers.LoginHandler).Methods("POST")
-	r.HandleFunc("/health", handlers.HealthCheck).Methods("GET")
-	r.HandleFunc("/products/compare/{barcode}", handlers.CompareByBarcode).Methods("GET")
-	r.HandleFunc("/products/{id}", handlers.GetProductByID).Methods("GET")
-	r.HandleFunc("/products", handlers.GetProducts).Methods("GET")
-	r.HandleFunc("/admin", handlers.AdminPage).Methods("GET")
-
-	authRouter := r.PathPrefix("").Subrouter()
-	authRouter.Use(middleware.AuthMiddleware)
+	configPath := flag.String("config", "", "path to a YAML config file (defaults to $CONFIG_FILE)")
+	flag.Parse()
 
-	authRouter.HandleFunc("/basket/compare", handlers.CompareBasket).Methods("POST")
-	authRouter.HandleFunc("/users", handlers.GetUsersHandler).Methods("GET")
-	authRouter.HandleFunc("/me", handlers.GetCurrentUserHandler).Methods("GET")
-
-	authRouter.HandleFunc("/supermarkets/stats", handlers.GetSupermarketStats).Methods("GET")
+	// The mock provider is independent of the server's configuration.
+	if flag.Arg(0) == "mock-oidc" {
+		runMockOIDC(flag.Args()[1:])
+		return
+	}
 
-	authRouter.HandleFunc("/products", handlers.CreateProduct).Methods("POST")
-	authRouter.HandleFunc("/products/{id}", handlers.UpdateProduct).Methods("PUT")
-	authRouter.HandleFunc("/products/{id}", handlers.DeleteProduct).Methods("DELETE")
+	// Migrations only need the database settings.
+	if flag.Arg(0) == "migrate" {
+		cfg, err := config.LoadDatabase(*configPath)
+		if err != nil {
+			log.Fatal("Configuration error: ", err)
+		}
+		runMigrate(cfg, flag.Args()[1:])
+		return
+	}
 
-	r.HandleFunc("/supermarkets", handlers.GetSupermarkets).Methods("GET")
-	r.HandleFunc("/supermarkets/{id}", handlers.GetSupermarketByID).Methods("GET")
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("Configuration error: ", err)
+	}
+	auth.Configure(cfg.Auth)
 
-	adminRouter := r.PathPrefix("").Subrouter()
-	adminRouter.Use(middleware.AuthMiddleware, middleware.AdminMiddleware)
-	adminRouter.HandleFunc("/admin/supermarkets", handlers.CreateSupermarket).Methods("POST")
-	adminRouter.HandleFunc("/admin/supermarkets/{id}", handlers.UpdateSupermarket).Methods("PUT")
-	adminRouter.HandleFunc("/admin/supermarkets/{id}", handlers.DeleteSupermarket).Methods("DELETE")
+	stores, err := openStores(cfg.Database)
+	if err != nil {
+		log.Fatal("Database initialization failed:", err)
+	}
+	opts := handlers.Options{
+		Mailer:    mail.New(cfg.Mail),
+		PublicURL: cfg.Server.PublicURL,
+		Login:     cfg.Login,
+	}
+	if cfg.OIDC.Enabled() {
+		opts.OIDC = oidc.New(cfg.OIDC, cfg.Server.PublicURL+"/login/oidc/callback")
+		log.Printf("Single sign-on through %s", cfg.OIDC.Issuer)
+	}
+	h := handlers.New(stores, opts)
+	r := handlers.NewRouter(h, middleware.NewAuthenticator(stores))
 
 	r.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("http://localhost:8080/swagger/doc.json"),
+		httpSwagger.URL(cfg.Server.PublicURL+"/swagger/doc.json"),
 		httpSwagger.DocExpansion("none"),
 		httpSwagger.DomID("swagger-ui"),
 	))
@@ -72,19 +79,32 @@ func main() {
 	r.PathPrefix("/").Handler(fs)
 
 	server := &http.Server{
-		Addr:         ":8080",
+		Addr:         cfg.Server.Addr,
 		Handler:      r,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	log.Println("Server starting on http://localhost:8080")
+	log.Printf("Server starting on %s", cfg.Server.Addr)
 	log.Println("Default admin: admin@example.com / admin123")
-	log.Println("Swagger docs available at http://localhost:8080/swagger/index.html")
-	log.Println("Frontend available at http://localhost:8080")
+	log.Printf("Swagger docs available at %s/swagger/index.html", cfg.Server.PublicURL)
+	log.Printf("Frontend available at %s", cfg.Server.PublicURL)
 
 	if err := server.ListenAndServe(); err != nil {
 		log.Fatal("Server failed:", err)
 	}
 }
+
+// openStores connects the configured storage backend.
+func openStores(cfg config.DatabaseConfig) (*repository.Stores, error) {
+	if cfg.Driver == config.DriverMemory {
+		log.Println("Using in-memory storage; data is lost on exit")
+		return memory.NewStores(), nil
+	}
+	db, err := repository.Init(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewPostgresStores(db), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"supermarket-catalogue/internal/config"
+	"supermarket-catalogue/internal/repository"
+)
+
+const migrateUsage = `usage: migrate up | migrate down N | migrate status`
+
+// runMigrate implements the migrate subcommand. It connects without the
+// schema check so it can bring an out-of-date database forward.
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	if cfg.Database.Driver != config.DriverPostgres {
+		log.Fatal("migrate only applies to the postgres driver")
+	}
+	db, err := repository.Open(cfg.Database)
+	if err != nil {
+		log.Fatal("Database connection failed:", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := repository.MigrateUp(db)
+		for _, v := range applied {
+			log.Printf("applied migration %04d", v)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			log.Println("schema already up to date")
+		}
+
+	case "down":
+		if len(args) != 2 {
+			log.Fatal(migrateUsage)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			log.Fatal("migrate down: N must be a positive number")
+		}
+		reverted, err := repository.MigrateDown(db, n)
+		for _, v := range reverted {
+			log.Printf("reverted migration %04d", v)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	case "status":
+		statuses, err := repository.MigrationStatuses(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d  %-30s %s\n", st.Version, st.Name, state)
+		}
+
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
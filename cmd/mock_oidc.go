@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+
+	"supermarket-catalogue/internal/oidc/oidctest"
+)
+
+// runMockOIDC implements the mock-oidc subcommand, which runs a local
+// OpenID Connect provider that logs every visitor in as one user. Point
+// OIDC_ISSUER and OIDC_CLIENT_ID at it to try single sign-on.
+func runMockOIDC(args []string) {
+	fs := flag.NewFlagSet("mock-oidc", flag.ExitOnError)
+	issuer := fs.String("issuer", "http://localhost:9090", "issuer URL; the provider listens on its host and port")
+	clientID := fs.String("client-id", "supermarket-catalogue", "client ID the provider accepts")
+	subject := fs.String("subject", "mock-user", "subject of the logged in user")
+	email := fs.String("email", "sso@example.com", "email address of the logged in user")
+	name := fs.String("name", "SSO User", "name of the logged in user")
+	unverified := fs.Bool("unverified", false, "report the email address as not verified")
+	fs.Parse(args)
+
+	u, err := url.Parse(*issuer)
+	if err != nil || u.Host == "" {
+		log.Fatal("mock-oidc: -issuer must be an absolute URL")
+	}
+	p, err := oidctest.NewProvider(*issuer, *clientID, oidctest.User{
+		Subject:       *subject,
+		Email:         *email,
+		EmailVerified: !*unverified,
+		Name:          *name,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Mock OpenID Connect provider at %s, logging everyone in as %s", *issuer, *email)
+	log.Fatal(http.ListenAndServe(u.Host, p))
+}
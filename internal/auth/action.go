@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Purposes of action tokens, the signed single-use tokens that authorise one
+// step of an account flow, such as the links sent by email.
+const (
+	PurposePasswordReset = "password_reset"
+	PurposeEmailVerify   = "email_verify"
+	// PurposeLoginMFA tokens are the challenge handed out between the
+	// password and the second factor of a login.
+	PurposeLoginMFA = "login_mfa"
+	// PurposeOIDCLogin tokens carry a completed single sign-on back to the
+	// browser, which exchanges them for a session.
+	PurposeOIDCLogin = "oidc_login"
+)
+
+// Lifetimes of action tokens.
+const (
+	PasswordResetTTL = time.Hour
+	EmailVerifyTTL   = 48 * time.Hour
+	LoginMFATTL      = 5 * time.Minute
+	OIDCLoginTTL     = 2 * time.Minute
+)
+
+// GenerateActionToken signs a token for purpose. fingerprint binds it to
+// account state that the action changes (see PasswordFingerprint), so
+// changing that state invalidates every outstanding token.
+func GenerateActionToken(purpose string, userID int, email, fingerprint string, ttl time.Duration) (string, error) {
+	return sign(&Claims{
+		UserID:      userID,
+		Email:       email,
+		Purpose:     purpose,
+		Fingerprint: fingerprint,
+	}, ttl)
+}
+
+// VerifyActionToken checks the signature, expiry and purpose of an action
+// token. Making it single-use is up to the caller, which should record
+// claims.Id once the action succeeds.
+func VerifyActionToken(tokenString, purpose string) (*Claims, error) {
+	claims, err := parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("wrong token purpose")
+	}
+	return claims, nil
+}
+
+// PasswordFingerprint is a short digest of a password hash, embedded in
+// reset tokens so they stop working once the password changes.
+func PasswordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
@@ -1,20 +1,61 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
 
+	"supermarket-catalogue/internal/config"
+	"supermarket-catalogue/internal/models"
+
 	"github.com/dgrijalva/jwt-go"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecret = []byte("your-secret-key-change-in-production")
+var (
+	jwtSecret  []byte
+	tokenTTL   = 15 * time.Minute
+	refreshTTL = 30 * 24 * time.Hour
+	mfaRoles   = map[string]bool{}
+)
+
+// Configure sets the signing secret, token lifetimes and the roles that must
+// use two-factor authentication. It must be called before any token is
+// issued or verified.
+func Configure(cfg config.AuthConfig) {
+	jwtSecret = []byte(cfg.JWTSecret)
+	tokenTTL = cfg.TokenTTL
+	refreshTTL = cfg.RefreshTTL
+	mfaRoles = map[string]bool{}
+	for _, role := range cfg.MFARequiredRoles {
+		mfaRoles[role] = true
+	}
+}
+
+// MFARequired reports whether accounts with role must complete two-factor
+// authentication to use their privileges.
+func MFARequired(role string) bool {
+	return mfaRoles[role]
+}
 
+// Claims are carried by access tokens. StandardClaims.Id is the token ID
+// checked against the revocation list; SessionID names the refresh token
+// family the token was issued from. MFA records that the session passed a
+// second factor. Purpose is only set on action tokens, which VerifyToken
+// refuses.
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID        int    `json:"user_id"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	SessionID     string `json:"sid,omitempty"`
+	MFA           bool   `json:"mfa,omitempty"`
+	Purpose       string `json:"purpose,omitempty"`
+	Fingerprint   string `json:"fp,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -28,22 +69,91 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func GenerateToken(userID int, email, role string) (string, error) {
-	claims := &Claims{
-		UserID: userID,
-		Email:  email,
-		Role:   role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
+// GenerateToken issues a short-lived access token for the given session.
+// mfa is set when the session was opened with a second factor.
+func GenerateToken(user *models.User, sessionID string, mfa bool) (string, error) {
+	return sign(&Claims{
+		UserID:        user.ID,
+		Email:         user.Email,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified,
+		SessionID:     sessionID,
+		MFA:           mfa,
+	}, tokenTTL)
+}
+
+// sign fills in the token ID and lifetime of claims and signs them.
+func sign(claims *Claims, ttl time.Duration) (string, error) {
+	jti, err := RandomToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        jti,
+		ExpiresAt: now.Add(ttl).Unix(),
+		IssuedAt:  now.Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
 
+// TokenTTL is the lifetime of access tokens.
+func TokenTTL() time.Duration {
+	return tokenTTL
+}
+
+// RefreshTTL is the lifetime of refresh tokens.
+func RefreshTTL() time.Duration {
+	return refreshTTL
+}
+
+// RandomToken returns 32 random bytes, URL-safe base64 encoded. It is used
+// for refresh tokens, token IDs and session IDs.
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// apiKeyPrefix marks API keys, so leaked ones are easy to spot in code and
+// logs.
+const apiKeyPrefix = "sck_"
+
+// GenerateAPIKey returns a new API key and the short prefix shown in key
+// listings to tell keys apart.
+func GenerateAPIKey() (key, prefix string, err error) {
+	token, err := RandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + token
+	return key, key[:len(apiKeyPrefix)+8], nil
+}
+
+// HashToken is how opaque tokens are stored, so a leaked table cannot be
+// replayed.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyToken checks an access token.
 func VerifyToken(tokenString string) (*Claims, error) {
+	claims, err := parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("not an access token")
+	}
+	return claims, nil
+}
+
+func parse(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// purposeOIDCFlow marks tokens that carry an OIDCFlow.
+const purposeOIDCFlow = "oidc_flow"
+
+// OIDCFlowTTL is how long a user has to log in at the identity provider.
+const OIDCFlowTTL = 10 * time.Minute
+
+// OIDCFlow holds the per-login secrets of an OpenID Connect login, kept by
+// the browser in a signed cookie between the redirect to the provider and
+// the callback. Binding them to the browser stops an attacker from
+// completing a login they started in someone else's browser.
+type OIDCFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+type oidcFlowClaims struct {
+	OIDCFlow
+	Purpose string `json:"purpose"`
+	jwt.StandardClaims
+}
+
+// NewOIDCFlow draws fresh secrets for a login and signs them into a token.
+func NewOIDCFlow() (*OIDCFlow, string, error) {
+	var flow OIDCFlow
+	for _, dst := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		v, err := RandomToken()
+		if err != nil {
+			return nil, "", err
+		}
+		*dst = v
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &oidcFlowClaims{
+		OIDCFlow: flow,
+		Purpose:  purposeOIDCFlow,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: now.Add(OIDCFlowTTL).Unix(),
+			IssuedAt:  now.Unix(),
+		},
+	})
+	signed, err := token.SignedString(jwtSecret)
+	if err != nil {
+		return nil, "", err
+	}
+	return &flow, signed, nil
+}
+
+// VerifyOIDCFlow checks a token from NewOIDCFlow and returns its secrets.
+func VerifyOIDCFlow(tokenString string) (*OIDCFlow, error) {
+	var claims oidcFlowClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purposeOIDCFlow {
+		return nil, errors.New("wrong token purpose")
+	}
+	return &claims.OIDCFlow, nil
+}
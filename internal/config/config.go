@@ -1,9 +1,385 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"supermarket-catalogue/internal/models"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the typed configuration shared by the server, the repository and
+// the auth package. Values are resolved in order: built-in defaults, then the
+// optional YAML file, then environment variables.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Login    LoginConfig    `yaml:"login"`
+	OIDC     OIDCConfig     `yaml:"oidc"`
+	Mail     MailConfig     `yaml:"mail"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr"`
+	// PublicURL is the externally reachable base URL, used for links such
+	// as the Swagger spec location.
+	PublicURL string `yaml:"public_url"`
+}
+
+// Storage drivers accepted in DatabaseConfig.Driver.
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+type DatabaseConfig struct {
+	// Driver selects the storage backend: "postgres" (default) or "memory",
+	// which keeps everything in process for demos and tests.
+	Driver   string `yaml:"driver"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+type AuthConfig struct {
+	JWTSecret string `yaml:"jwt_secret"`
+	// TokenTTL is the lifetime of access tokens; RefreshTTL that of the
+	// refresh tokens used to renew them.
+	TokenTTL   time.Duration `yaml:"token_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+	// MFARequiredRoles lists the roles whose privileged routes are refused
+	// until the account has logged in with two-factor authentication.
+	MFARequiredRoles []string `yaml:"mfa_required_roles"`
+}
+
+// LoginConfig throttles failed password logins per account and per client
+// address.
+type LoginConfig struct {
+	// MaxFailures consecutive failures lock an account for Lockout;
+	// MaxIPFailures do the same for a client address.
+	MaxFailures   int `yaml:"max_failures"`
+	MaxIPFailures int `yaml:"max_ip_failures"`
+	// Backoff is the wait imposed after the first failure. It doubles with
+	// every further failure.
+	Backoff time.Duration `yaml:"backoff"`
+	Lockout time.Duration `yaml:"lockout"`
+}
+
+// OIDCConfig enables single sign-on through an OpenID Connect provider. It
+// is off while Issuer is empty. The provider must accept
+// <public_url>/login/oidc/callback as a redirect URI.
+type OIDCConfig struct {
+	// Issuer is the provider's issuer URL, under which it publishes
+	// /.well-known/openid-configuration.
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+}
+
+// Enabled reports whether OpenID Connect login is configured.
+func (o OIDCConfig) Enabled() bool {
+	return o.Issuer != ""
+}
+
+// Mail drivers accepted in MailConfig.Driver.
 const (
-	DBHost     = "localhost"
-	DBPort     = 5432
-	DBUser     = "postgres"
-	DBPassword = "210624"
-	DBName     = "supermarket_catalogue_db"
-)
\ No newline at end of file
+	MailDriverLog  = "log"
+	MailDriverSMTP = "smtp"
+)
+
+type MailConfig struct {
+	// Driver is "log" (default), which writes messages to File or, when
+	// File is empty, the server log, or "smtp".
+	Driver   string `yaml:"driver"`
+	File     string `yaml:"file"`
+	From     string `yaml:"from"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// DSN returns the lib/pq connection string for the database.
+func (d DatabaseConfig) DSN() string {
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password='%s' dbname=%s sslmode=%s",
+		d.Host, d.Port, d.User, quote.Replace(d.Password), d.Name, d.SSLMode,
+	)
+}
+
+func defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:      ":8080",
+			PublicURL: "http://localhost:8080",
+		},
+		Database: DatabaseConfig{
+			Driver:  DriverPostgres,
+			Host:    "localhost",
+			Port:    5432,
+			User:    "postgres",
+			Name:    "supermarket_catalogue_db",
+			SSLMode: "disable",
+		},
+		Auth: AuthConfig{
+			TokenTTL:   15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Login: LoginConfig{
+			MaxFailures:   5,
+			MaxIPFailures: 50,
+			Backoff:       time.Second,
+			Lockout:       15 * time.Minute,
+		},
+		Mail: MailConfig{
+			Driver: MailDriverLog,
+			From:   "no-reply@localhost",
+			Port:   587,
+		},
+	}
+}
+
+// Load builds the configuration. path names an optional YAML file; when it is
+// empty the CONFIG_FILE environment variable is consulted instead.
+func Load(path string) (*Config, error) {
+	cfg, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadDatabase builds the configuration like Load but only requires the
+// database settings, for commands such as migrate that never serve requests.
+func LoadDatabase(path string) (*Config, error) {
+	cfg, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	if problems := cfg.databaseProblems(); len(problems) > 0 {
+		return nil, errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return cfg, nil
+}
+
+// read merges the defaults, the YAML file and the environment.
+func read(path string) (*Config, error) {
+	cfg := defaults()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func applyEnv(cfg *Config) error {
+	setString(&cfg.Server.Addr, "SERVER_ADDR")
+	setString(&cfg.Server.PublicURL, "PUBLIC_URL")
+
+	setString(&cfg.Database.Driver, "DB_DRIVER")
+	setString(&cfg.Database.Host, "DB_HOST")
+	if err := setInt(&cfg.Database.Port, "DB_PORT"); err != nil {
+		return err
+	}
+	setString(&cfg.Database.User, "DB_USER")
+	setString(&cfg.Database.Password, "DB_PASSWORD")
+	setString(&cfg.Database.Name, "DB_NAME")
+	setString(&cfg.Database.SSLMode, "DB_SSLMODE")
+
+	setString(&cfg.Auth.JWTSecret, "JWT_SECRET")
+	if err := setDuration(&cfg.Auth.TokenTTL, "JWT_TOKEN_TTL"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.Auth.RefreshTTL, "JWT_REFRESH_TTL"); err != nil {
+		return err
+	}
+	setList(&cfg.Auth.MFARequiredRoles, "MFA_REQUIRED_ROLES")
+
+	if err := setInt(&cfg.Login.MaxFailures, "LOGIN_MAX_FAILURES"); err != nil {
+		return err
+	}
+	if err := setInt(&cfg.Login.MaxIPFailures, "LOGIN_MAX_IP_FAILURES"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.Login.Backoff, "LOGIN_BACKOFF"); err != nil {
+		return err
+	}
+	if err := setDuration(&cfg.Login.Lockout, "LOGIN_LOCKOUT"); err != nil {
+		return err
+	}
+
+	setString(&cfg.OIDC.Issuer, "OIDC_ISSUER")
+	setString(&cfg.OIDC.ClientID, "OIDC_CLIENT_ID")
+	setString(&cfg.OIDC.ClientSecret, "OIDC_CLIENT_SECRET")
+
+	setString(&cfg.Mail.Driver, "MAIL_DRIVER")
+	setString(&cfg.Mail.File, "MAIL_FILE")
+	setString(&cfg.Mail.From, "MAIL_FROM")
+	setString(&cfg.Mail.Host, "SMTP_HOST")
+	if err := setInt(&cfg.Mail.Port, "SMTP_PORT"); err != nil {
+		return err
+	}
+	setString(&cfg.Mail.Username, "SMTP_USERNAME")
+	setString(&cfg.Mail.Password, "SMTP_PASSWORD")
+	return nil
+}
+
+// Validate reports every missing or malformed setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+	if c.Server.Addr == "" {
+		problems = append(problems, "server.addr (SERVER_ADDR) is required")
+	}
+	problems = append(problems, c.databaseProblems()...)
+	if c.Auth.JWTSecret == "" {
+		problems = append(problems, "auth.jwt_secret (JWT_SECRET) is required")
+	} else if len(c.Auth.JWTSecret) < 32 {
+		problems = append(problems, "auth.jwt_secret (JWT_SECRET) must be at least 32 characters")
+	}
+	if c.Auth.TokenTTL <= 0 {
+		problems = append(problems, "auth.token_ttl (JWT_TOKEN_TTL) must be positive")
+	}
+	if c.Auth.RefreshTTL < c.Auth.TokenTTL {
+		problems = append(problems, "auth.refresh_ttl (JWT_REFRESH_TTL) must not be shorter than auth.token_ttl")
+	}
+	for _, role := range c.Auth.MFARequiredRoles {
+		if !models.ValidRole(role) {
+			problems = append(problems, fmt.Sprintf("auth.mfa_required_roles (MFA_REQUIRED_ROLES): unknown role %q", role))
+		}
+	}
+	if c.Login.MaxFailures <= 0 {
+		problems = append(problems, "login.max_failures (LOGIN_MAX_FAILURES) must be positive")
+	}
+	if c.Login.MaxIPFailures <= 0 {
+		problems = append(problems, "login.max_ip_failures (LOGIN_MAX_IP_FAILURES) must be positive")
+	}
+	if c.Login.Backoff < 0 {
+		problems = append(problems, "login.backoff (LOGIN_BACKOFF) must not be negative")
+	}
+	if c.Login.Lockout <= 0 {
+		problems = append(problems, "login.lockout (LOGIN_LOCKOUT) must be positive")
+	}
+	if c.OIDC.Enabled() {
+		if u, err := url.Parse(c.OIDC.Issuer); err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			problems = append(problems, "oidc.issuer (OIDC_ISSUER) must be an http or https URL")
+		}
+		if c.OIDC.ClientID == "" {
+			problems = append(problems, "oidc.client_id (OIDC_CLIENT_ID) is required when oidc.issuer is set")
+		}
+	}
+	switch c.Mail.Driver {
+	case MailDriverLog:
+	case MailDriverSMTP:
+		if c.Mail.Host == "" {
+			problems = append(problems, "mail.host (SMTP_HOST) is required for the smtp driver")
+		}
+		if c.Mail.Port <= 0 || c.Mail.Port > 65535 {
+			problems = append(problems, "mail.port (SMTP_PORT) must be between 1 and 65535")
+		}
+	default:
+		problems = append(problems, "mail.driver (MAIL_DRIVER) must be log or smtp")
+	}
+	if c.Mail.From == "" {
+		problems = append(problems, "mail.from (MAIL_FROM) is required")
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (c *Config) databaseProblems() []string {
+	var problems []string
+	switch c.Database.Driver {
+	case DriverMemory:
+	case DriverPostgres:
+		if c.Database.Host == "" {
+			problems = append(problems, "database.host (DB_HOST) is required")
+		}
+		if c.Database.Port <= 0 || c.Database.Port > 65535 {
+			problems = append(problems, "database.port (DB_PORT) must be between 1 and 65535")
+		}
+		if c.Database.User == "" {
+			problems = append(problems, "database.user (DB_USER) is required")
+		}
+		if c.Database.Name == "" {
+			problems = append(problems, "database.name (DB_NAME) is required")
+		}
+	default:
+		problems = append(problems, "database.driver (DB_DRIVER) must be postgres or memory")
+	}
+	return problems
+}
+
+func setString(dst *string, key string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = v
+	}
+}
+
+// setList reads a comma-separated list; an empty variable clears it.
+func setList(dst *[]string, key string) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return
+	}
+	*dst = nil
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*dst = append(*dst, item)
+		}
+	}
+}
+
+func setInt(dst *int, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func setDuration(dst *time.Duration, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/basket/compare": {
             "post": {
-                "description": "Given a list of (barcode, quantity) items, compute cost to buy that basket in each supermarket and list missing items",
+                "description": "Given a list of (barcode, quantity) items, compute cost to buy that basket in each supermarket and list missing items, with the cheapest same-category substitute for each. With strategy \"split\", buy every item where it is cheapest instead, visiting at most max_stores stores",
                 "consumes": [
                     "application/json"
                 ],
@@ -95,7 +95,7 @@ const docTemplate = `{
         },
         "/products": {
             "get": {
-                "description": "Retrieve all products from the supermarket catalogue",
+                "description": "Page through the catalogue, filtered and sorted by the query parameters",
                 "consumes": [
                     "application/json"
                 ],
@@ -106,15 +106,113 @@ const docTemplate = `{
                     "products"
                 ],
                 "summary": "Get all products",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive substring of the product name",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only products of this supermarket",
+                        "name": "supermarket_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only products of this category",
+                        "name": "category_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Exact barcode",
+                        "name": "barcode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Lowest price, inclusive",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Highest price, inclusive",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Drop products with no stock",
+                        "name": "in_stock",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "enum": [
+                            "price",
+                            "unit_price",
+                            "name",
+                            "last_updated"
+                        ],
+                        "description": "Sort key; products are ordered by id without it",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "description": "Sort direction, also applied to the default order by id",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starting at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Products per page (default 10)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Product"
+                            "type": "object",
+                            "properties": {
+                                "limit": {
+                                    "type": "integer"
+                                },
+                                "page": {
+                                    "type": "integer"
+                                },
+                                "products": {
+                                    "type": "array",
+                                    "items": {
+                                        "$ref": "#/definitions/models.Product"
+                                    }
+                                },
+                                "total": {
+                                    "type": "integer"
+                                }
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             },
@@ -370,7 +468,8 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "quantity": {
-                    "type": "integer"
+                    "type": "integer",
+                    "minimum": 1
                 }
             }
         },
@@ -382,6 +481,19 @@ const docTemplate = `{
                     "items": {
                         "$ref": "#/definitions/handlers.BasketItem"
                     }
+                },
+                "max_stores": {
+                    "description": "MaxStores caps how many stores a split basket may visit; 0 means no cap.",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "strategy": {
+                    "description": "Strategy is \"single\" (default) to price the whole basket at each store,\nor \"split\" to buy every item where it is cheapest.",
+                    "type": "string",
+                    "enum": [
+                        "single",
+                        "split"
+                    ]
                 }
             }
         },
@@ -393,6 +505,69 @@ const docTemplate = `{
                     "items": {
                         "$ref": "#/definitions/handlers.SupermarketTotal"
                     }
+                },
+                "split": {
+                    "$ref": "#/definitions/handlers.SplitBasket"
+                }
+            }
+        },
+        "handlers.SplitBasket": {
+            "type": "object",
+            "properties": {
+                "max_stores": {
+                    "type": "integer"
+                },
+                "missing": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "stores": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.SplitStore"
+                    }
+                },
+                "total": {
+                    "type": "number"
+                }
+            }
+        },
+        "handlers.SplitItem": {
+            "type": "object",
+            "properties": {
+                "barcode": {
+                    "type": "string"
+                },
+                "cost": {
+                    "type": "number"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.SplitStore": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.SplitItem"
+                    }
+                },
+                "subtotal": {
+                    "type": "number"
+                },
+                "supermarket_id": {
+                    "type": "integer"
+                },
+                "supermarket_name": {
+                    "type": "string"
                 }
             }
         },
@@ -422,6 +597,9 @@ const docTemplate = `{
         "handlers.SupermarketTotal": {
             "type": "object",
             "properties": {
+                "alternative_total": {
+                    "type": "number"
+                },
                 "matched_items": {
                     "type": "integer"
                 },
@@ -431,6 +609,13 @@ const docTemplate = `{
                         "type": "string"
                     }
                 },
+                "substitutes": {
+                    "description": "Substitutes proposes a replacement for each missing barcode the store\ncan cover, and AlternativeTotal is Total plus their cost.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Substitute"
+                    }
+                },
                 "supermarket_id": {
                     "type": "integer"
                 },
@@ -528,6 +713,32 @@ const docTemplate = `{
                     "type": "number"
                 }
             }
+        },
+        "models.Substitute": {
+            "type": "object",
+            "properties": {
+                "barcode": {
+                    "type": "string"
+                },
+                "cost": {
+                    "type": "number"
+                },
+                "for_barcode": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/models"
+	"time"
+	"unicode/utf8"
+)
+
+// Password policy limits. bcrypt ignores everything after 72 bytes, so
+// longer passwords would only pretend to be stronger.
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+// commonPasswords are refused whatever their length; they are the first
+// guesses of any attack.
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "password123": true, "12345678": true,
+	"123456789": true, "1234567890": true, "qwertyuiop": true, "qwerty123": true,
+	"iloveyou": true, "11111111": true, "00000000": true, "abcd1234": true,
+	"letmein1": true, "welcome1": true, "admin123": true, "supermarket": true,
+}
+
+// checkPassword applies the password policy to a password chosen for user
+// and returns what is wrong with it, or "" if it is acceptable.
+func checkPassword(password string, user *models.User) string {
+	switch {
+	case utf8.RuneCountInString(password) < minPasswordLength:
+		return fmt.Sprintf("Password must be at least %d characters", minPasswordLength)
+	case len(password) > maxPasswordBytes:
+		return fmt.Sprintf("Password must be at most %d bytes", maxPasswordBytes)
+	case commonPasswords[strings.ToLower(password)]:
+		return "Password is too common"
+	}
+	local, _, _ := strings.Cut(user.Email, "@")
+	for _, personal := range []string{user.Email, local, user.Name} {
+		if personal != "" && strings.EqualFold(password, personal) {
+			return "Password must not be your name or email address"
+		}
+	}
+	return ""
+}
+
+// writePasswordProblem reports a checkPassword failure.
+func writePasswordProblem(w http.ResponseWriter, problem string) {
+	http.Error(w, fmt.Sprintf(`{"error": %q}`, problem), http.StatusBadRequest)
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// sendVerificationEmail mails user a link that proves they own the address.
+func (h *Handler) sendVerificationEmail(user *models.User) error {
+	token, err := auth.GenerateActionToken(auth.PurposeEmailVerify, user.ID, user.Email, "", auth.EmailVerifyTTL)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf(`Hello %s,
+
+confirm your email address for Supermarket Catalogue by opening this link:
+
+%s/?verify_token=%s
+
+The link is valid for %s. If you did not create an account, ignore this email.
+`, user.Name, h.publicURL, url.QueryEscape(token), hours(auth.EmailVerifyTTL))
+	return h.mailer.Send(user.Email, "Confirm your email address", body)
+}
+
+// hours renders a link lifetime for email text.
+func hours(d time.Duration) string {
+	if n := int(d.Hours()); n != 1 {
+		return fmt.Sprintf("%d hours", n)
+	}
+	return "1 hour"
+}
+
+// ForgotPasswordHandler mails a password reset link if the address belongs
+// to an account. It answers the same either way so it cannot be used to
+// probe for registered addresses.
+func (h *Handler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, `{"error": "email is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if user, err := h.users.GetUserByEmail(req.Email); err == nil {
+		if err := h.sendPasswordReset(user); err != nil {
+			log.Printf("password reset email to user %d failed: %v", user.ID, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) sendPasswordReset(user *models.User) error {
+	token, err := auth.GenerateActionToken(auth.PurposePasswordReset, user.ID, user.Email,
+		auth.PasswordFingerprint(user.Password), auth.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf(`Hello %s,
+
+someone asked to reset the password of your Supermarket Catalogue account.
+To choose a new password, open this link:
+
+%s/?reset_token=%s
+
+The link is valid for %s and works once. If you did not ask for a reset,
+ignore this email; your password stays as it is.
+`, user.Name, h.publicURL, url.QueryEscape(token), hours(auth.PasswordResetTTL))
+	return h.mailer.Send(user.Email, "Reset your password", body)
+}
+
+// ResetPasswordHandler sets a new password from a reset token and signs the
+// account out everywhere.
+func (h *Handler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, `{"error": "token and password are required"}`, http.StatusBadRequest)
+		return
+	}
+	claims, err := auth.VerifyActionToken(req.Token, auth.PurposePasswordReset)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid or expired token"}`, http.StatusBadRequest)
+		return
+	}
+	// Looking the account up by the address the link was sent to also
+	// voids the link if the address has changed since.
+	user, err := h.users.GetUserByEmail(claims.Email)
+	if err != nil || user.ID != claims.UserID || auth.PasswordFingerprint(user.Password) != claims.Fingerprint {
+		http.Error(w, `{"error": "Invalid or expired token"}`, http.StatusBadRequest)
+		return
+	}
+	if problem := checkPassword(req.Password, user); problem != "" {
+		writePasswordProblem(w, problem)
+		return
+	}
+	if !h.consumeActionToken(w, claims) {
+		return
+	}
+
+	if err := h.users.UpdatePassword(user.ID, req.Password); err != nil {
+		http.Error(w, `{"error": "Failed to update password"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(user.ID); err != nil {
+		log.Printf("revoking sessions of user %d after password reset failed: %v", user.ID, err)
+	}
+	// Proving control of the mailbox is enough to lift a lockout.
+	if err := h.logins.ClearLoginAttempts(accountLoginKey(user.Email)); err != nil {
+		log.Printf("clearing failed logins for user %d: %v", user.ID, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmailHandler marks the address in a verification token as
+// confirmed.
+func (h *Handler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, `{"error": "token is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims, err := auth.VerifyActionToken(req.Token, auth.PurposeEmailVerify)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid or expired token"}`, http.StatusBadRequest)
+		return
+	}
+	user, err := h.users.GetUserByEmail(claims.Email)
+	if err != nil || user.ID != claims.UserID {
+		http.Error(w, `{"error": "Invalid or expired token"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.consumeActionToken(w, claims) {
+		return
+	}
+
+	if err := h.users.SetEmailVerified(user.ID); err != nil {
+		http.Error(w, `{"error": "Failed to verify email"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerificationHandler mails the current user a fresh verification
+// link.
+func (h *Handler) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("X-User-ID"))
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+		return
+	}
+	user, err := h.users.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+	if user.EmailVerified {
+		http.Error(w, `{"error": "Email already verified"}`, http.StatusConflict)
+		return
+	}
+	if err := h.sendVerificationEmail(user); err != nil {
+		http.Error(w, `{"error": "Failed to send email"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// consumeActionToken spends the token's ID, refusing a token that has been
+// used before.
+func (h *Handler) consumeActionToken(w http.ResponseWriter, claims *auth.Claims) bool {
+	fresh, err := h.tokens.ConsumeTokenID(claims.Id, claims.UserID, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check token"}`, http.StatusInternalServerError)
+		return false
+	}
+	if !fresh {
+		http.Error(w, `{"error": "Token has already been used"}`, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+func TestUnverifiedUserCannotWrite(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	s.createUser("Max", "max@example.com", "password123", models.RoleManager, false)
+	unverified := s.login("max@example.com", "password123")
+
+	expectStatus(t, s.request("POST", "/products", models.Product{Name: "Milk", Price: 1}, bearer(unverified.Token)...), http.StatusForbidden)
+	expectStatus(t, s.request("GET", "/me", nil, bearer(unverified.Token)...), http.StatusOK)
+}
+
+func TestRegistrationVerifiesEmail(t *testing.T) {
+	mailer := &testMailer{}
+	s := newTestServer(t, handlers.Options{Mailer: mailer})
+	body := models.User{Name: "Vera", Email: "vera@example.com", Password: "long enough secret", Role: models.RoleAdmin}
+	var session models.AuthResponse
+	decodeResponse(t, s.request("POST", "/register", body), http.StatusOK, &session)
+
+	var me models.User
+	decodeResponse(t, s.request("GET", "/me", nil, bearer(session.Token)...), http.StatusOK, &me)
+	if me.Role != models.RoleUser || me.EmailVerified {
+		t.Fatalf("registered %+v, want an unverified user", me)
+	}
+
+	token := mailer.linkToken(t, "verify_token")
+	expectStatus(t, s.request("POST", "/email/verify", map[string]string{"token": token}), http.StatusNoContent)
+	expectStatus(t, s.request("POST", "/email/verify", map[string]string{"token": token}), http.StatusBadRequest)
+	decodeResponse(t, s.request("GET", "/me", nil, bearer(session.Token)...), http.StatusOK, &me)
+	if !me.EmailVerified {
+		t.Fatal("address not verified after following the link")
+	}
+	expectStatus(t, s.request("POST", "/email/resend", nil, bearer(session.Token)...), http.StatusConflict)
+}
+
+func TestPasswordReset(t *testing.T) {
+	mailer := &testMailer{}
+	s := newTestServer(t, handlers.Options{Mailer: mailer})
+	s.createUser("Rita", "rita@example.com", "password123", models.RoleUser, true)
+	session := s.login("rita@example.com", "password123")
+
+	// Unknown addresses get the same answer and no email.
+	expectStatus(t, s.request("POST", "/password/forgot", map[string]string{"email": "nobody@example.com"}), http.StatusAccepted)
+	if len(mailer.sent) != 0 {
+		t.Fatalf("mailed %d messages for an unknown address", len(mailer.sent))
+	}
+	expectStatus(t, s.request("POST", "/password/forgot", map[string]string{"email": "rita@example.com"}), http.StatusAccepted)
+	token := mailer.linkToken(t, "reset_token")
+
+	weak := map[string]string{"token": token, "password": "short"}
+	expectStatus(t, s.request("POST", "/password/reset", weak), http.StatusBadRequest)
+	reset := map[string]string{"token": token, "password": "a new long secret"}
+	expectStatus(t, s.request("POST", "/password/reset", reset), http.StatusNoContent)
+
+	// The link works once, and the reset signs out existing sessions.
+	reset["password"] = "another long secret"
+	expectStatus(t, s.request("POST", "/password/reset", reset), http.StatusBadRequest)
+	expectStatus(t, s.request("GET", "/me", nil, bearer(session.Token)...), http.StatusUnauthorized)
+	rec := s.request("POST", "/login", models.AuthRequest{Email: "rita@example.com", Password: "password123"})
+	expectStatus(t, rec, http.StatusUnauthorized)
+	s.login("rita@example.com", "a new long secret")
+}
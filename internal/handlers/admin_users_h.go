@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// targetUser parses the {id} of an admin user route. Admins may not change
+// or remove their own account here, so the last admin cannot lock everyone
+// out by accident.
+func targetUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return 0, false
+	}
+	if strconv.Itoa(id) == r.Header.Get("X-User-ID") {
+		http.Error(w, `{"error":"admins cannot change their own account here"}`, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeUserError reports a store error from an admin user route.
+func writeUserError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// writeUser responds with the current state of user id.
+func (h *Handler) writeUser(w http.ResponseWriter, id int) {
+	user, err := h.users.GetUserByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+func (h *Handler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req roleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		http.Error(w, `{"error":"unknown role"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.users.UpdateUserRole(id, req.Role); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Printf("user %d role set to %s by user %s", id, req.Role, r.Header.Get("X-User-ID"))
+	h.writeUser(w, id)
+}
+
+// DisableUser blocks the account from logging in and ends its sessions.
+func (h *Handler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, true)
+}
+
+func (h *Handler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setUserDisabled(w, r, false)
+}
+
+func (h *Handler) setUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+	if !disabled {
+		user, err := h.users.GetUserByID(id)
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		if user.Erased {
+			http.Error(w, `{"error":"erased accounts cannot be enabled"}`, http.StatusConflict)
+			return
+		}
+	}
+
+	if err := h.users.SetUserDisabled(id, disabled); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if disabled {
+		if err := h.tokens.RevokeUserTokens(id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	log.Printf("user %d disabled=%t by user %s", id, disabled, r.Header.Get("X-User-ID"))
+	h.writeUser(w, id)
+}
+
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.users.DeleteUser(id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Printf("user %d deleted by user %s", id, r.Header.Get("X-User-ID"))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type createAPIKeyRequest struct {
+	Name          string     `json:"name"`
+	Scopes        []string   `json:"scopes"`
+	SupermarketID int        `json:"supermarket_id"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+// createAPIKeyResponse is the only place the key itself is ever shown.
+type createAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey *models.APIKey `json:"api_key"`
+}
+
+// CreateAPIKey issues a key for a machine client. Send it in the X-API-Key
+// header.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, `{"error":"name is required and at most 100 characters"}`, http.StatusBadRequest)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		http.Error(w, `{"error":"at least one scope is required"}`, http.StatusBadRequest)
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !models.ValidScope(scope) {
+			http.Error(w, `{"error":"unknown scope"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, `{"error":"expires_at must be in the future"}`, http.StatusBadRequest)
+		return
+	}
+	if req.SupermarketID != 0 {
+		if _, err := h.supermarkets.GetSupermarket(req.SupermarketID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				http.Error(w, `{"error":"supermarket not found"}`, http.StatusBadRequest)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	createdBy, _ := strconv.Atoi(r.Header.Get("X-User-ID"))
+	apiKey := &models.APIKey{
+		Name:          req.Name,
+		Prefix:        prefix,
+		Scopes:        req.Scopes,
+		SupermarketID: req.SupermarketID,
+		CreatedBy:     createdBy,
+		ExpiresAt:     req.ExpiresAt,
+		KeyHash:       auth.HashToken(key),
+	}
+	if err := h.apiKeys.CreateAPIKey(apiKey); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("API key %d (%s) created by user %d", apiKey.ID, apiKey.Name, createdBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createAPIKeyResponse{Key: key, APIKey: apiKey})
+}
+
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.apiKeys.ListAPIKeys()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey stops a key from working at once. The record is kept for
+// the audit trail.
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.apiKeys.RevokeAPIKey(id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("API key %d revoked by user %s", id, r.Header.Get("X-User-ID"))
+	w.WriteHeader(http.StatusNoContent)
+}
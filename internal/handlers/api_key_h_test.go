@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+// createAPIKey issues a key as the admin and returns its ID and the key.
+func (s *testServer) createAPIKey(adminToken string, scopes []string, supermarketID int) (int, string) {
+	s.t.Helper()
+	body := map[string]interface{}{"name": "till", "scopes": scopes, "supermarket_id": supermarketID}
+	rec := s.request("POST", "/admin/api-keys", body, bearer(adminToken)...)
+	var resp struct {
+		Key    string        `json:"key"`
+		APIKey models.APIKey `json:"api_key"`
+	}
+	decodeResponse(s.t, rec, http.StatusCreated, &resp)
+	return resp.APIKey.ID, resp.Key
+}
+
+func TestAPIKeyBoundToSupermarket(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	own := s.createSupermarket(admin.Token, "Own")
+	other := s.createSupermarket(admin.Token, "Other")
+	_, key := s.createAPIKey(admin.Token, []string{models.ScopeProductsWrite}, own.ID)
+
+	product := models.Product{Name: "Milk", Price: 1.2, SupermarketID: own.ID}
+	var created models.Product
+	decodeResponse(t, s.request("POST", "/products", product, "X-API-Key", key), http.StatusCreated, &created)
+	if created.OwnerID != 0 {
+		t.Errorf("product created by API key has owner %d, want none", created.OwnerID)
+	}
+
+	product.SupermarketID = other.ID
+	expectStatus(t, s.request("POST", "/products", product, "X-API-Key", key), http.StatusForbidden)
+	created.SupermarketID = other.ID
+	path := fmt.Sprintf("/products/%d", created.ID)
+	expectStatus(t, s.request("PUT", path, created, "X-API-Key", key), http.StatusForbidden)
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	_, key := s.createAPIKey(admin.Token, []string{models.ScopeProductsWrite}, 0)
+
+	// products:write grants nothing beyond product writes, and keys never
+	// act on a user account.
+	expectStatus(t, s.request("POST", "/admin/supermarkets", map[string]string{"name": "Shop"}, "X-API-Key", key), http.StatusForbidden)
+	expectStatus(t, s.request("GET", "/users", nil, "X-API-Key", key), http.StatusForbidden)
+	expectStatus(t, s.request("POST", "/admin/api-keys", map[string]interface{}{"name": "x", "scopes": []string{models.ScopeProductsWrite}}, "X-API-Key", key), http.StatusForbidden)
+	expectStatus(t, s.request("GET", "/me", nil, "X-API-Key", key), http.StatusForbidden)
+
+	body := map[string]interface{}{"name": "till", "scopes": []string{"users:write"}}
+	expectStatus(t, s.request("POST", "/admin/api-keys", body, bearer(admin.Token)...), http.StatusBadRequest)
+}
+
+func TestRevokedAPIKey(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	id, key := s.createAPIKey(admin.Token, []string{models.ScopeProductsWrite}, 0)
+
+	product := models.Product{Name: "Bread", Price: 2}
+	expectStatus(t, s.request("POST", "/products", product, "X-API-Key", key), http.StatusCreated)
+
+	expectStatus(t, s.request("DELETE", fmt.Sprintf("/admin/api-keys/%d", id), nil, bearer(admin.Token)...), http.StatusNoContent)
+	expectStatus(t, s.request("POST", "/products", product, "X-API-Key", key), http.StatusUnauthorized)
+	expectStatus(t, s.request("POST", "/products", product, "X-API-Key", key+"x"), http.StatusUnauthorized)
+}
@@ -1,10 +1,20 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"math"
 	"net/http"
-	"supermarket-catalogue/internal/repository"
+	"sort"
+	"supermarket-catalogue/internal/models"
+)
+
+const (
+	strategySingle = "single"
+	strategySplit  = "split"
+
+	// maxSplitCombinations bounds the exhaustive search over store subsets;
+	// above it the split optimiser falls back to adding stores greedily.
+	maxSplitCombinations = 20000
 )
 
 type BasketItem struct {
@@ -14,6 +24,11 @@ type BasketItem struct {
 
 type BasketRequest struct {
 	Items []BasketItem `json:"items"`
+	// Strategy is "single" (default) to price the whole basket at each store,
+	// or "split" to buy every item where it is cheapest.
+	Strategy string `json:"strategy,omitempty"`
+	// MaxStores caps how many stores a split basket may visit; 0 means no cap.
+	MaxStores int `json:"max_stores,omitempty"`
 }
 
 type SupermarketTotal struct {
@@ -22,13 +37,44 @@ type SupermarketTotal struct {
 	Total           float64  `json:"total"`
 	Missing         []string `json:"missing"`
 	MatchedItems    int      `json:"matched_items"`
+	// Substitutes proposes a replacement for each missing barcode the store
+	// can cover, and AlternativeTotal is Total plus their cost.
+	Substitutes      []models.Substitute `json:"substitutes,omitempty"`
+	AlternativeTotal float64             `json:"alternative_total"`
+}
+
+type SplitItem struct {
+	Barcode  string  `json:"barcode"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+	Cost     float64 `json:"cost"`
+}
+
+type SplitStore struct {
+	SupermarketID   int         `json:"supermarket_id"`
+	SupermarketName string      `json:"supermarket_name,omitempty"`
+	Subtotal        float64     `json:"subtotal"`
+	Items           []SplitItem `json:"items"`
+}
+
+type SplitBasket struct {
+	Total     float64      `json:"total"`
+	MaxStores int          `json:"max_stores,omitempty"`
+	Stores    []SplitStore `json:"stores"`
+	Missing   []string     `json:"missing"`
 }
 
 type BasketResponse struct {
-	Results []SupermarketTotal `json:"results"`
+	Results []SupermarketTotal `json:"results,omitempty"`
+	Split   *SplitBasket       `json:"split,omitempty"`
+}
+
+type basketStore struct {
+	ID   int
+	Name string
 }
 
-func CompareBasket(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CompareBasket(w http.ResponseWriter, r *http.Request) {
 	var req BasketRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid body", http.StatusBadRequest)
@@ -38,108 +84,310 @@ func CompareBasket(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "no items provided", http.StatusBadRequest)
 		return
 	}
-
-	type sm struct {
-		ID   int
-		Name string
+	for _, it := range req.Items {
+		if it.Quantity < 1 {
+			http.Error(w, "quantity must be at least 1", http.StatusBadRequest)
+			return
+		}
 	}
-	supermarkets := []sm{}
-	rows, err := repository.DB.Query("SELECT id, name FROM supermarkets")
-	if err != nil {
-		http.Error(w, "database error: "+err.Error(), http.StatusInternalServerError)
+	if req.Strategy == "" {
+		req.Strategy = strategySingle
+	}
+	if req.Strategy != strategySingle && req.Strategy != strategySplit {
+		http.Error(w, "strategy must be single or split", http.StatusBadRequest)
 		return
 	}
-	for rows.Next() {
-		var s sm
-		if err := rows.Scan(&s.ID, &s.Name); err != nil {
-			rows.Close()
-			http.Error(w, "scan error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		supermarkets = append(supermarkets, s)
+	if req.MaxStores < 0 {
+		http.Error(w, "max_stores must not be negative", http.StatusBadRequest)
+		return
 	}
-	rows.Close()
 
+	supermarkets, err := h.basketStores()
+	if err != nil {
+		http.Error(w, "database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	if len(supermarkets) == 0 {
 		http.Error(w, "no supermarkets available", http.StatusInternalServerError)
 		return
 	}
 
-	type smState struct {
-		Name       string
-		Total      float64
-		MissingMap map[string]bool
-		Matched    int
+	prices, err := h.products.CheapestOffers(basketBarcodes(req.Items))
+	if err != nil {
+		http.Error(w, "database error: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-	state := map[int]*smState{}
-	for _, s := range supermarkets {
-		mm := make(map[string]bool)
-		for _, it := range req.Items {
-			mm[it.Barcode] = true
+
+	resp := BasketResponse{}
+	if req.Strategy == strategySplit {
+		resp.Split = splitBasket(req.Items, supermarkets, prices, req.MaxStores)
+	} else {
+		resp.Results = singleStoreTotals(req.Items, supermarkets, prices)
+		if err := h.addSubstitutes(resp.Results, req.Items); err != nil {
+			http.Error(w, "database error: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
-		state[s.ID] = &smState{
-			Name:       s.Name,
-			Total:      0,
-			MissingMap: mm,
-			Matched:    0,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) basketStores() ([]basketStore, error) {
+	items, err := h.supermarkets.ListSupermarkets()
+	if err != nil {
+		return nil, err
+	}
+	supermarkets := make([]basketStore, 0, len(items))
+	for _, s := range items {
+		supermarkets = append(supermarkets, basketStore{ID: s.ID, Name: s.Name})
+	}
+	return supermarkets, nil
+}
+
+// basketBarcodes returns the distinct barcodes of the basket in order.
+func basketBarcodes(items []BasketItem) []string {
+	seen := map[string]bool{}
+	barcodes := []string{}
+	for _, it := range items {
+		if !seen[it.Barcode] {
+			seen[it.Barcode] = true
+			barcodes = append(barcodes, it.Barcode)
 		}
 	}
+	return barcodes
+}
 
-	for _, it := range req.Items {
-		minPricePerSM := map[int]float64{}
-		rows, err := repository.DB.Query("SELECT supermarket_id, COALESCE(unit_price, price) FROM products WHERE barcode = $1", it.Barcode)
-		if err != nil {
-			http.Error(w, "database error: "+err.Error(), http.StatusInternalServerError)
-			return
+// singleStoreTotals prices the whole basket at each supermarket.
+func singleStoreTotals(items []BasketItem, supermarkets []basketStore, prices map[string]map[int]float64) []SupermarketTotal {
+	results := []SupermarketTotal{}
+	for _, s := range supermarkets {
+		total := SupermarketTotal{
+			SupermarketID:   s.ID,
+			SupermarketName: s.Name,
+			Missing:         []string{},
 		}
-		for rows.Next() {
-			var sid sql.NullInt64
-			var eff sql.NullFloat64
-			if err := rows.Scan(&sid, &eff); err != nil {
-				rows.Close()
-				http.Error(w, "scan error: "+err.Error(), http.StatusInternalServerError)
-				return
+		seen := map[string]bool{}
+		for _, it := range items {
+			price, ok := prices[it.Barcode][s.ID]
+			if ok {
+				total.Total += price * float64(it.Quantity)
 			}
-			if !sid.Valid || !eff.Valid {
+			if seen[it.Barcode] {
 				continue
 			}
-			sidInt := int(sid.Int64)
-			price := eff.Float64
-			if cur, ok := minPricePerSM[sidInt]; !ok || price < cur {
-				minPricePerSM[sidInt] = price
+			seen[it.Barcode] = true
+			if ok {
+				total.MatchedItems++
+			} else {
+				total.Missing = append(total.Missing, it.Barcode)
 			}
 		}
-		rows.Close()
+		total.AlternativeTotal = total.Total
+		results = append(results, total)
+	}
+	return results
+}
 
-		for _, s := range supermarkets {
-			if price, ok := minPricePerSM[s.ID]; ok {
-				state[s.ID].Total += price * float64(it.Quantity)
-				if state[s.ID].MissingMap[it.Barcode] {
-					state[s.ID].Matched++
-					state[s.ID].MissingMap[it.Barcode] = false
-				}
+// addSubstitutes fills in a substitute for every missing item a store can
+// replace and folds their cost into the store's AlternativeTotal.
+func (h *Handler) addSubstitutes(results []SupermarketTotal, items []BasketItem) error {
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, res := range results {
+		for _, bc := range res.Missing {
+			if !seen[bc] {
+				seen[bc] = true
+				missing = append(missing, bc)
 			}
 		}
 	}
+	if len(missing) == 0 {
+		return nil
+	}
 
-	resp := BasketResponse{}
+	subs, err := h.products.CheapestSubstitutes(missing)
+	if err != nil {
+		return err
+	}
+
+	quantities := map[string]int{}
+	for _, it := range items {
+		quantities[it.Barcode] += it.Quantity
+	}
+
+	for i := range results {
+		res := &results[i]
+		for _, bc := range res.Missing {
+			sub, ok := subs[bc][res.SupermarketID]
+			if !ok {
+				continue
+			}
+			sub.Quantity = quantities[bc]
+			sub.Cost = sub.Price * float64(sub.Quantity)
+			res.Substitutes = append(res.Substitutes, sub)
+			res.AlternativeTotal += sub.Cost
+		}
+	}
+	return nil
+}
+
+// splitBasket assigns every item to its cheapest store while visiting at most
+// maxStores stores. It prefers the store set that covers the most items and,
+// among those, the one with the lowest combined total.
+func splitBasket(items []BasketItem, supermarkets []basketStore, prices map[string]map[int]float64, maxStores int) *SplitBasket {
+	// Only stores that carry at least one basket item are worth visiting.
+	candidates := []int{}
+	names := map[int]string{}
 	for _, s := range supermarkets {
-		st := state[s.ID]
-		missing := []string{}
-		for bc, miss := range st.MissingMap {
-			if miss {
-				missing = append(missing, bc)
+		names[s.ID] = s.Name
+		for _, it := range items {
+			if _, ok := prices[it.Barcode][s.ID]; ok {
+				candidates = append(candidates, s.ID)
+				break
 			}
 		}
-		resp.Results = append(resp.Results, SupermarketTotal{
-			SupermarketID:   s.ID,
-			SupermarketName: st.Name,
-			Total:           st.Total,
-			Missing:         missing,
-			MatchedItems:    st.Matched,
+	}
+
+	size := len(candidates)
+	if maxStores > 0 && maxStores < size {
+		size = maxStores
+	}
+
+	var chosen []int
+	if binomial(len(candidates), size) <= maxSplitCombinations {
+		chosen = bestStoreSet(items, candidates, prices, size)
+	} else {
+		chosen = greedyStoreSet(items, candidates, prices, size)
+	}
+
+	result := &SplitBasket{MaxStores: maxStores, Stores: []SplitStore{}, Missing: []string{}}
+	byStore := map[int]*SplitStore{}
+	order := []int{}
+	missing := map[string]bool{}
+	for _, it := range items {
+		sid, price, ok := cheapestIn(prices[it.Barcode], chosen)
+		if !ok {
+			if !missing[it.Barcode] {
+				missing[it.Barcode] = true
+				result.Missing = append(result.Missing, it.Barcode)
+			}
+			continue
+		}
+		st, exists := byStore[sid]
+		if !exists {
+			st = &SplitStore{SupermarketID: sid, SupermarketName: names[sid]}
+			byStore[sid] = st
+			order = append(order, sid)
+		}
+		cost := price * float64(it.Quantity)
+		st.Items = append(st.Items, SplitItem{
+			Barcode:  it.Barcode,
+			Quantity: it.Quantity,
+			Price:    price,
+			Cost:     cost,
 		})
+		st.Subtotal += cost
+		result.Total += cost
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	sort.Ints(order)
+	for _, sid := range order {
+		result.Stores = append(result.Stores, *byStore[sid])
+	}
+	return result
+}
+
+// bestStoreSet tries every combination of size stores and keeps the best.
+// Adding a store never makes a basket worse, so smaller sets need no search.
+func bestStoreSet(items []BasketItem, candidates []int, prices map[string]map[int]float64, size int) []int {
+	var best []int
+	bestCovered, bestTotal := -1, math.Inf(1)
+
+	combo := make([]int, 0, size)
+	var walk func(start int)
+	walk = func(start int) {
+		if len(combo) == size {
+			covered, total := evaluateStoreSet(items, combo, prices)
+			if covered > bestCovered || (covered == bestCovered && total < bestTotal) {
+				bestCovered, bestTotal = covered, total
+				best = append([]int(nil), combo...)
+			}
+			return
+		}
+		for i := start; i <= len(candidates)-(size-len(combo)); i++ {
+			combo = append(combo, candidates[i])
+			walk(i + 1)
+			combo = combo[:len(combo)-1]
+		}
+	}
+	walk(0)
+	return best
+}
+
+// greedyStoreSet adds, one at a time, the store that improves the basket most.
+func greedyStoreSet(items []BasketItem, candidates []int, prices map[string]map[int]float64, size int) []int {
+	chosen := []int{}
+	used := map[int]bool{}
+	for len(chosen) < size {
+		bestID := -1
+		bestCovered, bestTotal := -1, math.Inf(1)
+		for _, sid := range candidates {
+			if used[sid] {
+				continue
+			}
+			covered, total := evaluateStoreSet(items, append(chosen, sid), prices)
+			if covered > bestCovered || (covered == bestCovered && total < bestTotal) {
+				bestID, bestCovered, bestTotal = sid, covered, total
+			}
+		}
+		if bestID < 0 {
+			break
+		}
+		used[bestID] = true
+		chosen = append(chosen, bestID)
+	}
+	return chosen
+}
+
+// evaluateStoreSet returns how many basket lines the stores can supply and
+// what those lines cost when each is bought at the cheapest of them.
+func evaluateStoreSet(items []BasketItem, stores []int, prices map[string]map[int]float64) (int, float64) {
+	covered, total := 0, 0.0
+	for _, it := range items {
+		if _, price, ok := cheapestIn(prices[it.Barcode], stores); ok {
+			covered++
+			total += price * float64(it.Quantity)
+		}
+	}
+	return covered, total
+}
+
+func cheapestIn(offers map[int]float64, stores []int) (int, float64, bool) {
+	bestID, bestPrice, found := 0, 0.0, false
+	for _, sid := range stores {
+		price, ok := offers[sid]
+		if ok && (!found || price < bestPrice || (price == bestPrice && sid < bestID)) {
+			bestID, bestPrice, found = sid, price, true
+		}
+	}
+	return bestID, bestPrice, found
+}
+
+// binomial returns n choose k, saturating once it exceeds maxSplitCombinations.
+func binomial(n, k int) int {
+	if k < 0 || k > n {
+		return 0
+	}
+	if k > n-k {
+		k = n - k
+	}
+	result := 1
+	for i := 1; i <= k; i++ {
+		result = result * (n - k + i) / i
+		if result > maxSplitCombinations {
+			return maxSplitCombinations + 1
+		}
+	}
+	return result
 }
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSplitBasket(t *testing.T) {
+	stores := []basketStore{{ID: 1, Name: "A"}, {ID: 2, Name: "B"}, {ID: 3, Name: "C"}}
+	tests := []struct {
+		name      string
+		items     []BasketItem
+		prices    map[string]map[int]float64
+		maxStores int
+		// want maps each chosen store to its barcodes, in basket order.
+		want      map[int][]string
+		wantTotal float64
+		missing   []string
+	}{
+		{
+			name:  "cheapest store per item",
+			items: []BasketItem{{"milk", 1}, {"bread", 2}, {"eggs", 1}},
+			prices: map[string]map[int]float64{
+				"milk":  {1: 1.0, 2: 1.5, 3: 2.0},
+				"bread": {1: 3.0, 2: 2.0, 3: 2.5},
+				"eggs":  {1: 4.0, 2: 4.0, 3: 3.0},
+			},
+			want:      map[int][]string{1: {"milk"}, 2: {"bread"}, 3: {"eggs"}},
+			wantTotal: 1 + 4 + 3,
+			missing:   []string{},
+		},
+		{
+			name:  "max_stores caps the stores visited",
+			items: []BasketItem{{"milk", 1}, {"bread", 2}, {"eggs", 1}},
+			prices: map[string]map[int]float64{
+				"milk":  {1: 1.0, 2: 1.5, 3: 2.0},
+				"bread": {1: 3.0, 2: 2.0, 3: 2.5},
+				"eggs":  {1: 4.0, 2: 4.0, 3: 3.0},
+			},
+			maxStores: 2,
+			// {1,3} costs 1+5+3, {2,3} 1.5+4+3, {1,2} 1+4+4.
+			want:      map[int][]string{2: {"milk", "bread"}, 3: {"eggs"}},
+			wantTotal: 8.5,
+			missing:   []string{},
+		},
+		{
+			name:  "coverage beats price",
+			items: []BasketItem{{"milk", 1}, {"caviar", 1}},
+			prices: map[string]map[int]float64{
+				"milk":   {1: 5.0, 2: 1.0},
+				"caviar": {1: 50.0},
+			},
+			maxStores: 1,
+			want:      map[int][]string{1: {"milk", "caviar"}},
+			wantTotal: 55,
+			missing:   []string{},
+		},
+		{
+			name:      "no store carries anything",
+			items:     []BasketItem{{"milk", 1}},
+			prices:    map[string]map[int]float64{},
+			want:      map[int][]string{},
+			wantTotal: 0,
+			missing:   []string{"milk"},
+		},
+		{
+			name:  "duplicate barcodes",
+			items: []BasketItem{{"milk", 1}, {"bread", 1}, {"milk", 2}, {"bread", 1}},
+			prices: map[string]map[int]float64{
+				"milk": {1: 1.0, 2: 2.0},
+			},
+			want:      map[int][]string{1: {"milk", "milk"}},
+			wantTotal: 3,
+			missing:   []string{"bread"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitBasket(tt.items, stores, tt.prices, tt.maxStores)
+			chosen := map[int][]string{}
+			for _, st := range got.Stores {
+				sum := 0.0
+				for _, it := range st.Items {
+					chosen[st.SupermarketID] = append(chosen[st.SupermarketID], it.Barcode)
+					sum += it.Cost
+				}
+				if sum != st.Subtotal {
+					t.Errorf("store %d: subtotal %v, items cost %v", st.SupermarketID, st.Subtotal, sum)
+				}
+			}
+			if !reflect.DeepEqual(chosen, tt.want) {
+				t.Errorf("stores = %v, want %v", chosen, tt.want)
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("total = %v, want %v", got.Total, tt.wantTotal)
+			}
+			if !reflect.DeepEqual(got.Missing, tt.missing) {
+				t.Errorf("missing = %v, want %v", got.Missing, tt.missing)
+			}
+		})
+	}
+}
+
+func TestSplitBasketGreedyFallback(t *testing.T) {
+	// 30 stores taken 5 at a time are too many combinations to try.
+	const n, maxStores = 30, 5
+	if binomial(n, maxStores) <= maxSplitCombinations {
+		t.Fatalf("binomial(%d, %d) is within maxSplitCombinations", n, maxStores)
+	}
+
+	// Store i is the only one with item i and the cheapest for "milk".
+	stores := []basketStore{}
+	items := []BasketItem{{"milk", 1}}
+	prices := map[string]map[int]float64{"milk": {}}
+	for i := 1; i <= n; i++ {
+		stores = append(stores, basketStore{ID: i})
+		barcode := fmt.Sprintf("item-%d", i)
+		items = append(items, BasketItem{barcode, 1})
+		prices[barcode] = map[int]float64{i: float64(i)}
+		prices["milk"][i] = float64(n - i + 1)
+	}
+
+	got := splitBasket(items, stores, prices, maxStores)
+	if len(got.Stores) != maxStores {
+		t.Fatalf("visited %d stores, want %d", len(got.Stores), maxStores)
+	}
+	// Each store adds one item at the same total, so the greedy search
+	// takes stores 1 to 5 in order; milk is cheapest at store 5 of those.
+	want := 1 + 2 + 3 + 4 + 5 + float64(n-5+1)
+	if got.Total != want {
+		t.Errorf("total = %v, want %v", got.Total, want)
+	}
+	if len(got.Missing) != n-maxStores {
+		t.Errorf("%d items missing, want %d", len(got.Missing), n-maxStores)
+	}
+}
+
+func TestGreedyMatchesExhaustiveSearch(t *testing.T) {
+	items := []BasketItem{{"milk", 1}, {"bread", 2}, {"eggs", 1}}
+	prices := map[string]map[int]float64{
+		"milk":  {1: 1.0, 2: 1.5, 3: 2.0},
+		"bread": {1: 3.0, 2: 2.0},
+		"eggs":  {3: 3.0},
+	}
+	candidates := []int{1, 2, 3}
+	for size := 1; size <= len(candidates); size++ {
+		best := bestStoreSet(items, candidates, prices, size)
+		greedy := greedyStoreSet(items, candidates, prices, size)
+		bc, bt := evaluateStoreSet(items, best, prices)
+		gc, gt := evaluateStoreSet(items, greedy, prices)
+		if gc > bc || gc == bc && gt < bt {
+			t.Errorf("size %d: greedy %v (%d, %v) beats exhaustive %v (%d, %v)", size, greedy, gc, gt, best, bc, bt)
+		}
+		if len(greedy) != size || len(best) != size {
+			t.Errorf("size %d: greedy chose %v, exhaustive %v", size, greedy, best)
+		}
+	}
+}
+
+func TestBinomial(t *testing.T) {
+	tests := []struct{ n, k, want int }{
+		{5, 0, 1},
+		{5, 2, 10},
+		{5, 5, 1},
+		{3, 4, 0},
+		{30, 5, maxSplitCombinations + 1},
+	}
+	for _, tt := range tests {
+		if got := binomial(tt.n, tt.k); got != tt.want {
+			t.Errorf("binomial(%d, %d) = %d, want %d", tt.n, tt.k, got, tt.want)
+		}
+	}
+}
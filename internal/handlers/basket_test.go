@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+func TestCompareBasketRejectsQuantity(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	session := s.login(adminEmail, adminPassword)
+	s.createSupermarket(session.Token, "Shop")
+
+	for _, quantity := range []int{0, -1} {
+		body := handlers.BasketRequest{Items: []handlers.BasketItem{{Barcode: "4000001", Quantity: quantity}}}
+		expectStatus(t, s.request("POST", "/basket/compare", body, bearer(session.Token)...), http.StatusBadRequest)
+	}
+}
+
+func TestCompareBasketSplit(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	a := s.createSupermarket(admin.Token, "A")
+	b := s.createSupermarket(admin.Token, "B")
+	for _, p := range []models.Product{
+		{Name: "Milk", Price: 1, Barcode: "milk", SupermarketID: a.ID},
+		{Name: "Milk", Price: 2, Barcode: "milk", SupermarketID: b.ID},
+		{Name: "Bread", Price: 3, Barcode: "bread", SupermarketID: a.ID},
+		{Name: "Bread", Price: 2, Barcode: "bread", SupermarketID: b.ID},
+	} {
+		expectStatus(t, s.request("POST", "/products", p, bearer(admin.Token)...), http.StatusCreated)
+	}
+
+	body := handlers.BasketRequest{
+		Items:    []handlers.BasketItem{{Barcode: "milk", Quantity: 2}, {Barcode: "bread", Quantity: 1}},
+		Strategy: "split",
+	}
+	var resp handlers.BasketResponse
+	decodeResponse(t, s.request("POST", "/basket/compare", body, bearer(admin.Token)...), http.StatusOK, &resp)
+	if resp.Split == nil || resp.Split.Total != 4 || len(resp.Split.Stores) != 2 {
+		t.Fatalf("split = %+v, want milk at A and bread at B for 4", resp.Split)
+	}
+
+	body.MaxStores = 1
+	decodeResponse(t, s.request("POST", "/basket/compare", body, bearer(admin.Token)...), http.StatusOK, &resp)
+	if len(resp.Split.Stores) != 1 || resp.Split.Total != 5 {
+		t.Fatalf("split with max_stores 1 = %+v, want everything at A for 5", resp.Split)
+	}
+}
+
+func TestCompareBasketSubstitutes(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	a := s.createSupermarket(admin.Token, "A")
+	b := s.createSupermarket(admin.Token, "B")
+	var dairy, drinks models.Category
+	decodeResponse(t, s.request("POST", "/admin/categories", models.Category{Name: "Dairy"}, bearer(admin.Token)...), http.StatusCreated, &dairy)
+	decodeResponse(t, s.request("POST", "/admin/categories", models.Category{Name: "Drinks"}, bearer(admin.Token)...), http.StatusCreated, &drinks)
+	for _, p := range []models.Product{
+		{Name: "Milk", Price: 1, Unit: "l", Barcode: "milk", CategoryID: dairy.ID, SupermarketID: a.ID},
+		{Name: "Bread", Price: 2, Barcode: "bread", SupermarketID: a.ID},
+		{Name: "Bread", Price: 3, Barcode: "bread", SupermarketID: b.ID},
+		// B has no milk. Only the oat milk shares its category and unit and
+		// is the cheapest of those.
+		{Name: "Oat milk", Price: 1.5, Unit: "l", Barcode: "oat", CategoryID: dairy.ID, SupermarketID: b.ID},
+		{Name: "Goat milk", Price: 2.5, Unit: "l", Barcode: "goat", CategoryID: dairy.ID, SupermarketID: b.ID},
+		{Name: "Cheese", Price: 0.5, Unit: "kg", Barcode: "cheese", CategoryID: dairy.ID, SupermarketID: b.ID},
+		{Name: "Cola", Price: 0.2, Unit: "l", Barcode: "cola", CategoryID: drinks.ID, SupermarketID: b.ID},
+	} {
+		expectStatus(t, s.request("POST", "/products", p, bearer(admin.Token)...), http.StatusCreated)
+	}
+
+	body := handlers.BasketRequest{Items: []handlers.BasketItem{{Barcode: "milk", Quantity: 2}, {Barcode: "bread", Quantity: 1}}}
+	var resp handlers.BasketResponse
+	decodeResponse(t, s.request("POST", "/basket/compare", body, bearer(admin.Token)...), http.StatusOK, &resp)
+	totals := map[int]handlers.SupermarketTotal{}
+	for _, res := range resp.Results {
+		totals[res.SupermarketID] = res
+	}
+
+	if got := totals[a.ID]; len(got.Substitutes) != 0 || got.AlternativeTotal != got.Total || got.Total != 4 {
+		t.Errorf("A = %+v, want no substitutes and an alternative total of 4", got)
+	}
+	got := totals[b.ID]
+	if len(got.Substitutes) != 1 {
+		t.Fatalf("B substitutes = %+v, want only the oat milk", got.Substitutes)
+	}
+	sub := got.Substitutes[0]
+	if sub.ForBarcode != "milk" || sub.Barcode != "oat" || sub.Quantity != 2 || sub.Price != 1.5 || sub.Cost != 3 {
+		t.Errorf("B substitute = %+v, want 2 oat milk for 3", sub)
+	}
+	if got.Total != 3 || got.AlternativeTotal != 6 {
+		t.Errorf("B total = %v and alternative total = %v, want 3 and 6", got.Total, got.AlternativeTotal)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// GetCatalogItem returns the canonical item for a barcode together with every
+// supermarket's offer of it, cheapest first.
+func (h *Handler) GetCatalogItem(w http.ResponseWriter, r *http.Request) {
+	barcode := mux.Vars(r)["barcode"]
+
+	item, err := h.catalog.GetCatalogItem(barcode)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// UpdateCatalogItem corrects the shared name, brand, unit or category of a
+// barcode. Every offer is relisted under the new name, unit and category;
+// prices and stock are left untouched.
+func (h *Handler) UpdateCatalogItem(w http.ResponseWriter, r *http.Request) {
+	barcode := mux.Vars(r)["barcode"]
+
+	var item models.CatalogItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+		return
+	}
+	if item.Name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.validateProductCategory(w, item.CategoryID) {
+		return
+	}
+
+	item.Barcode = barcode
+	err := h.catalog.UpdateCatalogItem(&item)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
@@ -0,0 +1,43 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+func TestUpdateCatalogItemRelistsOffers(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	a := s.createSupermarket(admin.Token, "A")
+	b := s.createSupermarket(admin.Token, "B")
+	dairy := s.createCategory(admin.Token, "Dairy", 0)
+	for _, p := range []models.Product{
+		{Name: "Milk 1l", Price: 1, Barcode: "4000001", SupermarketID: a.ID},
+		{Name: "MILK UHT", Price: 1.1, Unit: "pcs", Barcode: "4000001", SupermarketID: b.ID},
+	} {
+		expectStatus(t, s.request("POST", "/products", p, bearer(admin.Token)...), http.StatusCreated)
+	}
+
+	item := models.CatalogItem{Name: "Whole milk", Unit: "l", CategoryID: dairy.ID}
+	expectStatus(t, s.request("PUT", "/admin/items/4000001", item, bearer(admin.Token)...), http.StatusOK)
+
+	var page struct {
+		Products []models.Product `json:"products"`
+	}
+	decodeResponse(t, s.request("GET", "/products?barcode=4000001", nil), http.StatusOK, &page)
+	if len(page.Products) != 2 {
+		t.Fatalf("listed %d offers, want 2", len(page.Products))
+	}
+	for _, p := range page.Products {
+		if p.Name != item.Name || p.Unit != item.Unit || p.CategoryID != dairy.ID {
+			t.Errorf("offer = %+v, want it listed as the corrected item", p)
+		}
+	}
+	decodeResponse(t, s.request("GET", "/products/search?q=whole", nil), http.StatusOK, &page)
+	if len(page.Products) != 2 {
+		t.Errorf("search for the new name found %d offers, want 2", len(page.Products))
+	}
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *Handler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	items, err := h.categories.ListCategories()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// GetCategoryTree returns every category nested under its parent, with the
+// top-level aisles at the root.
+func (h *Handler) GetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	items, err := h.categories.ListCategories()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	children := map[int][]models.Category{}
+	for _, c := range items {
+		children[c.ParentID] = append(children[c.ParentID], c)
+	}
+
+	var build func(parentID int) []models.Category
+	build = func(parentID int) []models.Category {
+		nodes := children[parentID]
+		for i := range nodes {
+			nodes[i].Children = build(nodes[i].ID)
+		}
+		return nodes
+	}
+
+	tree := build(0)
+	if tree == nil {
+		tree = []models.Category{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
+func (h *Handler) GetCategoryByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.categories.GetCategory(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// GetCategoryProducts pages through the products of a category. With
+// include_descendants=true products filed under any subcategory are included.
+func (h *Handler) GetCategoryProducts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	page, limit, window := parsePagination(r)
+	includeDescendants, _ := strconv.ParseBool(r.URL.Query().Get("include_descendants"))
+
+	ids := []int{id}
+	if includeDescendants {
+		ids, err = h.categories.CategorySubtree(id)
+	} else {
+		var exists bool
+		exists, err = h.categories.CategoryExists(id)
+		if err == nil && !exists {
+			err = repository.ErrNotFound
+		}
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	products, total, err := h.products.ListProducts(repository.ProductFilter{CategoryIDs: ids}, window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"category_id":         id,
+		"include_descendants": includeDescendants,
+		"products":            products,
+		"total":               total,
+		"page":                page,
+		"limit":               limit,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var c models.Category
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+		return
+	}
+	if c.Name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return
+	}
+	if c.OwnerID == 0 {
+		c.OwnerID, _ = strconv.Atoi(r.Header.Get("X-User-ID"))
+	}
+	if !h.validateParentCategory(w, 0, c.ParentID) {
+		return
+	}
+
+	if err := h.categories.CreateCategory(&c); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			http.Error(w, `{"error":"a sibling category already has this name"}`, http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var c models.Category
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+		return
+	}
+	if c.Name == "" {
+		http.Error(w, `{"error":"name is required"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.validateParentCategory(w, id, c.ParentID) {
+		return
+	}
+
+	c.ID = id
+	err = h.categories.UpdateCategory(&c)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			http.Error(w, `{"error":"a sibling category already has this name"}`, http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.categories.DeleteCategory(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			http.Error(w, `{"error":"a subcategory has the name of a top-level category"}`, http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateParentCategory checks that parentID names a stored category and,
+// for an existing category id, that it is not id itself or one of its
+// descendants, which would detach the whole branch from the tree.
+func (h *Handler) validateParentCategory(w http.ResponseWriter, id, parentID int) bool {
+	if parentID == 0 {
+		return true
+	}
+	exists, err := h.categories.CategoryExists(parentID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.Error(w, `{"error":"parent category not found"}`, http.StatusBadRequest)
+		return false
+	}
+	if id == 0 {
+		return true
+	}
+
+	subtree, err := h.categories.CategorySubtree(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		// The category itself is missing; the update reports the 404.
+		return true
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	for _, cid := range subtree {
+		if cid == parentID {
+			http.Error(w, `{"error":"category cannot be nested under itself"}`, http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
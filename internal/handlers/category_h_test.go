@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+// createCategory adds a category named name under parentID.
+func (s *testServer) createCategory(adminToken, name string, parentID int) models.Category {
+	s.t.Helper()
+	var c models.Category
+	rec := s.request("POST", "/admin/categories", models.Category{Name: name, ParentID: parentID}, bearer(adminToken)...)
+	decodeResponse(s.t, rec, http.StatusCreated, &c)
+	return c
+}
+
+func TestCategoryTree(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	dairy := s.createCategory(admin.Token, "Dairy", 0)
+	cheese := s.createCategory(admin.Token, "Cheese", dairy.ID)
+	hard := s.createCategory(admin.Token, "Hard cheese", cheese.ID)
+	s.createCategory(admin.Token, "Bakery", 0)
+
+	var tree []models.Category
+	decodeResponse(t, s.request("GET", "/categories/tree", nil), http.StatusOK, &tree)
+	if len(tree) != 2 {
+		t.Fatalf("tree has %d roots, want 2: %+v", len(tree), tree)
+	}
+	for _, root := range tree {
+		if root.ID != dairy.ID {
+			continue
+		}
+		if len(root.Children) != 1 || root.Children[0].ID != cheese.ID ||
+			len(root.Children[0].Children) != 1 || root.Children[0].Children[0].ID != hard.ID {
+			t.Fatalf("dairy branch = %+v, want dairy > cheese > hard cheese", root)
+		}
+	}
+
+	sm := s.createSupermarket(admin.Token, "Shop")
+	for _, p := range []models.Product{
+		{Name: "Milk", Price: 1, Barcode: "milk", CategoryID: dairy.ID, SupermarketID: sm.ID},
+		{Name: "Cheddar", Price: 3, Barcode: "cheddar", CategoryID: hard.ID, SupermarketID: sm.ID},
+	} {
+		expectStatus(t, s.request("POST", "/products", p, bearer(admin.Token)...), http.StatusCreated)
+	}
+	var page struct {
+		Total int `json:"total"`
+	}
+	path := fmt.Sprintf("/categories/%d/products", dairy.ID)
+	decodeResponse(t, s.request("GET", path, nil), http.StatusOK, &page)
+	if page.Total != 1 {
+		t.Errorf("dairy lists %d products, want 1", page.Total)
+	}
+	decodeResponse(t, s.request("GET", path+"?include_descendants=true", nil), http.StatusOK, &page)
+	if page.Total != 2 {
+		t.Errorf("dairy with its subtree lists %d products, want 2", page.Total)
+	}
+}
+
+func TestCategoryParentChecks(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	dairy := s.createCategory(admin.Token, "Dairy", 0)
+	cheese := s.createCategory(admin.Token, "Cheese", dairy.ID)
+	hard := s.createCategory(admin.Token, "Hard cheese", cheese.ID)
+
+	rec := s.request("POST", "/admin/categories", models.Category{Name: "Yoghurt", ParentID: 999}, bearer(admin.Token)...)
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	for _, parentID := range []int{dairy.ID, cheese.ID, hard.ID} {
+		body := models.Category{Name: "Dairy", ParentID: parentID}
+		rec := s.request("PUT", fmt.Sprintf("/admin/categories/%d", dairy.ID), body, bearer(admin.Token)...)
+		expectStatus(t, rec, http.StatusBadRequest)
+	}
+
+	// Moving a branch elsewhere is fine.
+	bakery := s.createCategory(admin.Token, "Bakery", 0)
+	body := models.Category{Name: "Cheese", ParentID: bakery.ID}
+	expectStatus(t, s.request("PUT", fmt.Sprintf("/admin/categories/%d", cheese.ID), body, bearer(admin.Token)...), http.StatusOK)
+}
+
+func TestCategorySiblingNamesAreUnique(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	dairy := s.createCategory(admin.Token, "Dairy", 0)
+	s.createCategory(admin.Token, "Cheese", dairy.ID)
+	milk := s.createCategory(admin.Token, "Milk", dairy.ID)
+	s.createCategory(admin.Token, "Milk", 0)
+
+	rec := s.request("POST", "/admin/categories", models.Category{Name: "Cheese", ParentID: dairy.ID}, bearer(admin.Token)...)
+	expectStatus(t, rec, http.StatusConflict)
+	rec = s.request("PUT", fmt.Sprintf("/admin/categories/%d", milk.ID), models.Category{Name: "Cheese", ParentID: dairy.ID}, bearer(admin.Token)...)
+	expectStatus(t, rec, http.StatusConflict)
+	// Deleting dairy would make its milk a second root named Milk.
+	expectStatus(t, s.request("DELETE", fmt.Sprintf("/admin/categories/%d", dairy.ID), nil, bearer(admin.Token)...), http.StatusConflict)
+	if _, err := s.stores.Categories.GetCategory(dairy.ID); err != nil {
+		t.Fatalf("dairy after the refused delete: %v", err)
+	}
+}
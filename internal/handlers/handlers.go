@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"supermarket-catalogue/internal/config"
+	"supermarket-catalogue/internal/mail"
+	"supermarket-catalogue/internal/oidc"
+	"supermarket-catalogue/internal/repository"
+)
+
+// Handler serves the HTTP API on top of the injected stores.
+type Handler struct {
+	products     repository.ProductStore
+	catalog      repository.CatalogStore
+	supermarkets repository.SupermarketStore
+	categories   repository.CategoryStore
+	users        repository.UserStore
+	tokens       repository.TokenStore
+	logins       repository.LoginAttemptStore
+	twoFactor    repository.TwoFactorStore
+	apiKeys      repository.APIKeyStore
+	identities   repository.IdentityStore
+
+	mailer    mail.Mailer
+	publicURL string
+	login     config.LoginConfig
+	oidc      *oidc.Provider
+}
+
+// Options carries the collaborators of Handler that are not stores.
+type Options struct {
+	Mailer mail.Mailer
+	// PublicURL prefixes the links sent in emails.
+	PublicURL string
+	// Login sets the failed login limits.
+	Login config.LoginConfig
+	// OIDC enables single sign-on; nil leaves it off.
+	OIDC *oidc.Provider
+}
+
+func New(stores *repository.Stores, opts Options) *Handler {
+	return &Handler{
+		products:     stores.Products,
+		catalog:      stores.Catalog,
+		supermarkets: stores.Supermarkets,
+		categories:   stores.Categories,
+		users:        stores.Users,
+		tokens:       stores.Tokens,
+		logins:       stores.Logins,
+		twoFactor:    stores.TwoFactor,
+		apiKeys:      stores.APIKeys,
+		identities:   stores.Identities,
+		mailer:       opts.Mailer,
+		publicURL:    opts.PublicURL,
+		login:        opts.Login,
+		oidc:         opts.OIDC,
+	}
+}
@@ -0,0 +1,202 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/config"
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/middleware"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+	"supermarket-catalogue/internal/repository/memory"
+)
+
+// The tests drive the full router over the in-memory stores, the way a
+// client sees the API. The memory store seeds this admin account.
+const (
+	adminEmail    = "admin@example.com"
+	adminPassword = "admin123"
+	testPublicURL = "http://catalogue.test"
+)
+
+// testLogin locks an account after three failed logins and a client address
+// after six, without the backoff between attempts.
+var testLogin = config.LoginConfig{MaxFailures: 3, MaxIPFailures: 6, Lockout: time.Minute}
+
+// TestMain silences the request log, which would bury test failures.
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+type testServer struct {
+	t      *testing.T
+	stores *repository.Stores
+	router http.Handler
+}
+
+// newTestServer serves the API over fresh memory stores. opts.Mailer
+// defaults to one that discards messages and opts.Login to testLogin.
+func newTestServer(t *testing.T, opts handlers.Options) *testServer {
+	t.Helper()
+	auth.Configure(config.AuthConfig{
+		JWTSecret:  "test-secret-0123456789abcdefghijklmnop",
+		TokenTTL:   15 * time.Minute,
+		RefreshTTL: time.Hour,
+	})
+	if opts.Mailer == nil {
+		opts.Mailer = &testMailer{}
+	}
+	if opts.Login == (config.LoginConfig{}) {
+		opts.Login = testLogin
+	}
+	if opts.PublicURL == "" {
+		opts.PublicURL = testPublicURL
+	}
+
+	stores := memory.NewStores()
+	h := handlers.New(stores, opts)
+	return &testServer{
+		t:      t,
+		stores: stores,
+		router: handlers.NewRouter(h, middleware.NewAuthenticator(stores)),
+	}
+}
+
+// request sends body, JSON-encoded unless it is a string, followed by header
+// name and value pairs. Requests come from 192.0.2.1 unless a RemoteAddr
+// pair says otherwise.
+func (s *testServer) request(method, path string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	for i := 0; i+1 < len(headers); i += 2 {
+		if headers[i] == "RemoteAddr" {
+			req.RemoteAddr = headers[i+1]
+			continue
+		}
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// bearer returns the header pair that authenticates as token.
+func bearer(token string) []string {
+	return []string{"Authorization", "Bearer " + token}
+}
+
+// createUser stores an account directly, bypassing registration.
+func (s *testServer) createUser(name, email, password, role string, verified bool) *models.User {
+	s.t.Helper()
+	user := &models.User{Name: name, Email: email, Password: password, Role: role, EmailVerified: verified}
+	if err := s.stores.Users.CreateUser(user); err != nil {
+		s.t.Fatal(err)
+	}
+	return user
+}
+
+// login logs in with a password and fails the test unless it succeeds
+// without a second factor.
+func (s *testServer) login(email, password string) *models.AuthResponse {
+	s.t.Helper()
+	rec := s.request("POST", "/login", models.AuthRequest{Email: email, Password: password})
+	var resp models.AuthResponse
+	decodeResponse(s.t, rec, http.StatusOK, &resp)
+	if resp.Token == "" {
+		s.t.Fatalf("login of %s returned no token: %s", email, rec.Body)
+	}
+	return &resp
+}
+
+// createSupermarket adds a supermarket as the admin.
+func (s *testServer) createSupermarket(adminToken, name string) models.Supermarket {
+	s.t.Helper()
+	rec := s.request("POST", "/admin/supermarkets", map[string]string{"name": name}, bearer(adminToken)...)
+	var sm models.Supermarket
+	decodeResponse(s.t, rec, http.StatusCreated, &sm)
+	return sm
+}
+
+// decodeResponse checks the status of rec and decodes its JSON body into v,
+// if v is not nil.
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder, status int, v interface{}) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, status, rec.Body)
+	}
+	if v == nil {
+		return
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+}
+
+// expectStatus fails the test unless rec has the given status.
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, status, rec.Body)
+	}
+}
+
+// testMailer keeps sent messages for inspection.
+type testMailer struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (m *testMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, to+"\n"+subject+"\n"+body)
+	return nil
+}
+
+// linkToken returns the value of query parameter name in the link of the
+// last message sent, failing the test if there is none.
+func (m *testMailer) linkToken(t *testing.T, name string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	last := m.sent[len(m.sent)-1]
+	i := strings.Index(last, name+"=")
+	if i < 0 {
+		t.Fatalf("email has no %s link:\n%s", name, last)
+	}
+	value, _, _ := strings.Cut(last[i+len(name)+1:], "\n")
+	token, err := url.QueryUnescape(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
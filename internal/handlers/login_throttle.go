@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"supermarket-catalogue/internal/repository"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxLoginBackoff caps the doubling wait between failed logins; longer
+// pauses are what the lockout is for.
+const maxLoginBackoff = 30 * time.Second
+
+// accountLoginKey and ipLoginKey name the failed login counters. Accounts
+// are keyed by the submitted email so unknown addresses are throttled the
+// same as real ones.
+func accountLoginKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipLoginKey uses the connection's address. Forwarding headers are not
+// trusted, as any client could set them to dodge the limit.
+func ipLoginKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return ipKey(host)
+}
+
+func ipKey(addr string) string {
+	return "ip:" + addr
+}
+
+// loginBackoff is the wait after the given number of consecutive failures.
+func (h *Handler) loginBackoff(failures int) time.Duration {
+	d := h.login.Backoff
+	for i := 1; i < failures && d < maxLoginBackoff; i++ {
+		d *= 2
+	}
+	if d > maxLoginBackoff {
+		d = maxLoginBackoff
+	}
+	return d
+}
+
+// loginWait returns how long the caller must wait before trying to log in
+// again: the longest of any lock or backoff on the given keys.
+func (h *Handler) loginWait(now time.Time, keys ...string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range keys {
+		a, err := h.logins.GetLoginAttempts(key)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		until := a.LastFailedAt.Add(h.loginBackoff(a.Failures))
+		if a.LockedUntil != nil && a.LockedUntil.After(until) {
+			until = *a.LockedUntil
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure counts a failed login against the account and client
+// address, locking either once it reaches its limit.
+func (h *Handler) recordLoginFailure(r *http.Request, email string) {
+	now := time.Now().UTC()
+	limits := map[string]int{
+		accountLoginKey(email): h.login.MaxFailures,
+		ipLoginKey(r):          h.login.MaxIPFailures,
+	}
+	for key, limit := range limits {
+		a, err := h.logins.RecordLoginFailure(key, now, h.login.Lockout)
+		if err != nil {
+			log.Printf("recording failed login for %s: %v", key, err)
+			continue
+		}
+		if a.Failures < limit || a.LockedUntil != nil && a.LockedUntil.After(now) {
+			continue
+		}
+		until := now.Add(h.login.Lockout)
+		if err := h.logins.LockLogin(key, until); err != nil {
+			log.Printf("locking %s: %v", key, err)
+			continue
+		}
+		log.Printf("login lockout: %s locked until %s after %d failed attempts",
+			key, until.Format(time.RFC3339), a.Failures)
+	}
+}
+
+// writeLoginThrottled refuses a login attempt made too soon.
+func writeLoginThrottled(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	http.Error(w, `{"error": "Too many failed login attempts, try again later"}`, http.StatusTooManyRequests)
+}
+
+// UnlockUser clears the failed login count and any lockout of an account.
+func (h *Handler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user, err := h.users.GetUserByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := h.logins.ClearLoginAttempts(accountLoginKey(user.Email)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("login unlock: user %d unlocked by user %s", id, r.Header.Get("X-User-ID"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnlockAddress clears the failed login count and any lockout of a client
+// address, e.g. an office behind one NAT.
+func (h *Handler) UnlockAddress(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(mux.Vars(r)["ip"])
+	if ip == nil {
+		http.Error(w, "invalid ip", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.logins.ClearLoginAttempts(ipKey(ip.String())); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("login unlock: address %s unlocked by user %s", ip, r.Header.Get("X-User-ID"))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+func TestLoginLockoutByAccount(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	wrong := models.AuthRequest{Email: adminEmail, Password: "wrong"}
+
+	for i := 0; i < testLogin.MaxFailures; i++ {
+		expectStatus(t, s.request("POST", "/login", wrong), http.StatusUnauthorized)
+	}
+
+	// The right password is refused while locked, from any address, and
+	// the response says when to retry.
+	right := models.AuthRequest{Email: adminEmail, Password: adminPassword}
+	rec := s.request("POST", "/login", right, "RemoteAddr", "198.51.100.7:4000")
+	expectStatus(t, rec, http.StatusTooManyRequests)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("locked login has no Retry-After header")
+	}
+
+	// Other accounts can still log in from the same address.
+	s.createUser("Other", "other@example.com", "correct horse", models.RoleUser, true)
+	s.login("other@example.com", "correct horse")
+}
+
+func TestLoginLockoutByAddress(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	s.createUser("Other", "other@example.com", "correct horse", models.RoleUser, true)
+
+	// Spread over many accounts, so no single account reaches its limit.
+	for i := 0; i < testLogin.MaxIPFailures; i++ {
+		rec := s.request("POST", "/login", models.AuthRequest{Email: "guess" + string(rune('a'+i)) + "@example.com", Password: "x"})
+		expectStatus(t, rec, http.StatusUnauthorized)
+	}
+
+	right := models.AuthRequest{Email: "other@example.com", Password: "correct horse"}
+	expectStatus(t, s.request("POST", "/login", right), http.StatusTooManyRequests)
+	expectStatus(t, s.request("POST", "/login", right, "RemoteAddr", "198.51.100.7:4000"), http.StatusOK)
+
+	// An admin can lift the lock on the address.
+	admin := s.request("POST", "/login", models.AuthRequest{Email: adminEmail, Password: adminPassword}, "RemoteAddr", "198.51.100.7:4000")
+	var session models.AuthResponse
+	decodeResponse(t, admin, http.StatusOK, &session)
+	expectStatus(t, s.request("POST", "/admin/ips/192.0.2.1/unlock", nil, bearer(session.Token)...), http.StatusNoContent)
+	expectStatus(t, s.request("POST", "/login", right), http.StatusOK)
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"supermarket-catalogue/internal/middleware"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/policy"
+	"supermarket-catalogue/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// canManageSupermarket reports whether the caller may change products of
+// the supermarket, writing a 403 when not. Admins may use any supermarket;
+// managers only those they own or are assigned to, which rules out
+// products without a supermarket. API keys are limited to their own
+// supermarket, if they have one.
+func (h *Handler) canManageSupermarket(w http.ResponseWriter, r *http.Request, supermarketID int) bool {
+	if middleware.Can(r, policy.ProductAnySupermarket) {
+		return true
+	}
+	if r.Header.Get("X-API-Key-ID") != "" {
+		keySupermarket, _ := strconv.Atoi(r.Header.Get("X-API-Supermarket-ID"))
+		if keySupermarket == 0 || keySupermarket == supermarketID {
+			return true
+		}
+		http.Error(w, `{"error": "API key is not valid for this supermarket"}`, http.StatusForbidden)
+		return false
+	}
+	userID, _ := strconv.Atoi(r.Header.Get("X-User-ID"))
+	manages := false
+	if supermarketID != 0 {
+		var err error
+		manages, err = h.supermarkets.ManagesSupermarket(supermarketID, userID)
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return false
+		}
+	}
+	if !manages {
+		http.Error(w, `{"error": "You do not manage this supermarket"}`, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// managerVars parses the {id} and {userID} of a supermarket manager route.
+func managerVars(w http.ResponseWriter, r *http.Request) (supermarketID, userID int, ok bool) {
+	vars := mux.Vars(r)
+	supermarketID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	userID, err = strconv.Atoi(vars["userID"])
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return supermarketID, userID, true
+}
+
+func (h *Handler) GetSupermarketManagers(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.supermarkets.GetSupermarket(id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	users, err := h.supermarkets.ListSupermarketManagers(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// AssignSupermarketManager lets a user with the manager role maintain the
+// supermarket's products.
+func (h *Handler) AssignSupermarketManager(w http.ResponseWriter, r *http.Request) {
+	supermarketID, userID, ok := managerVars(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.supermarkets.GetSupermarket(supermarketID); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	user, err := h.users.GetUserByID(userID)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if user.Role != models.RoleManager {
+		http.Error(w, `{"error":"user does not have the manager role"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.supermarkets.AssignManager(supermarketID, userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("user %d assigned to supermarket %d by user %s", userID, supermarketID, r.Header.Get("X-User-ID"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) UnassignSupermarketManager(w http.ResponseWriter, r *http.Request) {
+	supermarketID, userID, ok := managerVars(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.supermarkets.UnassignManager(supermarketID, userID)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Printf("user %d unassigned from supermarket %d by user %s", userID, supermarketID, r.Header.Get("X-User-ID"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// productForWrite loads product id for an update or delete and checks that
+// the caller manages its supermarket. It writes the response on failure.
+func (h *Handler) productForWrite(w http.ResponseWriter, r *http.Request, id int) (*models.Product, bool) {
+	p, err := h.products.GetProduct(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Product not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if !h.canManageSupermarket(w, r, p.SupermarketID) {
+		return nil, false
+	}
+	return p, true
+}
@@ -0,0 +1,34 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+func TestManagerLimitedToOwnSupermarkets(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	own := s.createSupermarket(admin.Token, "Own")
+	other := s.createSupermarket(admin.Token, "Other")
+	m := s.createUser("Mia", "mia@example.com", "password123", models.RoleManager, true)
+	path := fmt.Sprintf("/admin/supermarkets/%d/managers/%d", own.ID, m.ID)
+	expectStatus(t, s.request("PUT", path, nil, bearer(admin.Token)...), http.StatusNoContent)
+	manager := s.login("mia@example.com", "password123")
+
+	expectStatus(t, s.request("POST", "/admin/supermarkets", map[string]string{"name": "Mine"}, bearer(manager.Token)...), http.StatusForbidden)
+	expectStatus(t, s.request("POST", "/admin/products/import", "barcode,name,price\n", bearer(manager.Token)...), http.StatusForbidden)
+
+	product := models.Product{Name: "Milk", Price: 1.2, SupermarketID: own.ID}
+	var created models.Product
+	decodeResponse(t, s.request("POST", "/products", product, bearer(manager.Token)...), http.StatusCreated, &created)
+	product.SupermarketID = other.ID
+	expectStatus(t, s.request("POST", "/products", product, bearer(manager.Token)...), http.StatusForbidden)
+
+	// Moving a product out of a managed supermarket is refused as well.
+	created.SupermarketID = other.ID
+	expectStatus(t, s.request("PUT", fmt.Sprintf("/products/%d", created.ID), created, bearer(manager.Token)...), http.StatusForbidden)
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/oidc"
+	"supermarket-catalogue/internal/repository"
+)
+
+// oidcFlowCookie carries the auth.OIDCFlow of a login in progress.
+const oidcFlowCookie = "oidc_flow"
+
+type oidcTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// setOIDCFlowCookie stores or, with an empty value, clears the flow cookie.
+// It is Lax so that it comes along on the provider's redirect back to us.
+func (h *Handler) setOIDCFlowCookie(w http.ResponseWriter, value string) {
+	maxAge := int(auth.OIDCFlowTTL.Seconds())
+	if value == "" {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    value,
+		Path:     "/login/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.publicURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectToFrontend sends the browser to the frontend with one query
+// parameter, the way email links come back.
+func (h *Handler) redirectToFrontend(w http.ResponseWriter, r *http.Request, key, value string) {
+	http.Redirect(w, r, h.publicURL+"/?"+url.Values{key: {value}}.Encode(), http.StatusFound)
+}
+
+// OIDCLoginHandler starts single sign-on by sending the browser to the
+// identity provider.
+func (h *Handler) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		http.Error(w, `{"error": "Single sign-on is not configured"}`, http.StatusNotFound)
+		return
+	}
+
+	flow, cookie, err := auth.NewOIDCFlow()
+	if err != nil {
+		http.Error(w, `{"error": "Failed to start login"}`, http.StatusInternalServerError)
+		return
+	}
+	target, err := h.oidc.AuthCodeURL(r.Context(), flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		log.Printf("oidc login: %v", err)
+		http.Error(w, `{"error": "Identity provider is unavailable"}`, http.StatusBadGateway)
+		return
+	}
+
+	h.setOIDCFlowCookie(w, cookie)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// OIDCCallbackHandler is where the identity provider sends the browser
+// back. It finishes the login, links or creates the local account and
+// sends the browser on to the frontend with a short-lived sso_token, which
+// the frontend exchanges at OIDCTokenHandler. Failures arrive there as
+// sso_error.
+func (h *Handler) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		http.Error(w, `{"error": "Single sign-on is not configured"}`, http.StatusNotFound)
+		return
+	}
+	h.setOIDCFlowCookie(w, "")
+
+	user, problem := h.completeOIDCLogin(r)
+	if problem != "" {
+		h.redirectToFrontend(w, r, "sso_error", problem)
+		return
+	}
+	token, err := auth.GenerateActionToken(auth.PurposeOIDCLogin, user.ID, user.Email, "", auth.OIDCLoginTTL)
+	if err != nil {
+		h.redirectToFrontend(w, r, "sso_error", "Failed to generate token")
+		return
+	}
+	h.redirectToFrontend(w, r, "sso_token", token)
+}
+
+// completeOIDCLogin checks the provider's answer against the flow cookie,
+// redeems the code and returns the local account. On failure it returns a
+// message for the user; details go to the log.
+func (h *Handler) completeOIDCLogin(r *http.Request) (*models.User, string) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		log.Printf("oidc callback: provider returned %s: %s", e, q.Get("error_description"))
+		return nil, "Login was refused by the identity provider"
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		return nil, "Login session expired, please try again"
+	}
+	flow, err := auth.VerifyOIDCFlow(cookie.Value)
+	if err != nil || subtle.ConstantTimeCompare([]byte(flow.State), []byte(q.Get("state"))) != 1 {
+		return nil, "Login session expired, please try again"
+	}
+
+	rawIDToken, err := h.oidc.Exchange(r.Context(), q.Get("code"), flow.Verifier)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		return nil, "Login at the identity provider failed"
+	}
+	idToken, err := h.oidc.Verify(r.Context(), rawIDToken, flow.Nonce)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		return nil, "Login at the identity provider failed"
+	}
+
+	user, err := h.oidcUser(idToken)
+	if err != nil {
+		log.Printf("oidc callback: subject %q: %v", idToken.Subject, err)
+		return nil, "Failed to sign in"
+	}
+	if user == nil {
+		return nil, "The identity provider did not confirm your email address"
+	}
+	if user.Disabled {
+		return nil, "Account is disabled"
+	}
+	return user, ""
+}
+
+// oidcUser returns the account linked to the provider identity. An unlinked
+// identity is linked to the account with its email address, which is
+// created if needed, provided the provider has verified the address;
+// otherwise oidcUser returns nil.
+func (h *Handler) oidcUser(idToken *oidc.IDToken) (*models.User, error) {
+	identity, err := h.identities.GetIdentity(idToken.Issuer, idToken.Subject)
+	if err == nil {
+		return h.users.GetUserByID(identity.UserID)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	if idToken.Email == "" || !idToken.EmailVerified {
+		return nil, nil
+	}
+
+	user, err := h.users.GetUserByEmail(idToken.Email)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		if user, err = h.createOIDCUser(idToken); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !user.EmailVerified:
+		// Anyone can register an address they do not own. Now that the
+		// owner has shown up, drop the unproven password and its sessions.
+		if err := h.claimUnverifiedUser(user); err != nil {
+			return nil, err
+		}
+	}
+
+	err = h.identities.LinkIdentity(&models.Identity{
+		UserID:  user.ID,
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   idToken.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("linked user %d to subject %q at %s", user.ID, idToken.Subject, idToken.Issuer)
+	return user, nil
+}
+
+// createOIDCUser creates an account for a new single sign-on user. Its
+// random password is never revealed, so until the user resets it the
+// account can only be used through the provider.
+func (h *Handler) createOIDCUser(idToken *oidc.IDToken) (*models.User, error) {
+	password, err := auth.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	name := idToken.Name
+	if name == "" {
+		name, _, _ = strings.Cut(idToken.Email, "@")
+	}
+	user := &models.User{
+		Name:          name,
+		Email:         idToken.Email,
+		Password:      password,
+		Role:          models.RoleUser,
+		EmailVerified: true,
+	}
+	if err := h.users.CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// claimUnverifiedUser marks the address of user as verified and replaces
+// its password with a random one, signing out every session.
+func (h *Handler) claimUnverifiedUser(user *models.User) error {
+	password, err := auth.RandomToken()
+	if err != nil {
+		return err
+	}
+	if err := h.users.UpdatePassword(user.ID, password); err != nil {
+		return err
+	}
+	if err := h.tokens.RevokeUserTokens(user.ID); err != nil {
+		return err
+	}
+	if err := h.users.SetEmailVerified(user.ID); err != nil {
+		return err
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+// OIDCTokenHandler exchanges the sso_token from OIDCCallbackHandler for a
+// session, or for a two-factor challenge when the account has 2FA enabled.
+func (h *Handler) OIDCTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req oidcTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, `{"error": "token is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims, err := auth.VerifyActionToken(req.Token, auth.PurposeOIDCLogin)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid or expired token"}`, http.StatusUnauthorized)
+		return
+	}
+	if !h.consumeActionToken(w, claims) {
+		return
+	}
+
+	user, err := h.users.GetUserByEmail(claims.Email)
+	if err != nil || user.ID != claims.UserID {
+		http.Error(w, `{"error": "Invalid or expired token"}`, http.StatusUnauthorized)
+		return
+	}
+	if user.Disabled {
+		http.Error(w, `{"error": "Account is disabled"}`, http.StatusForbidden)
+		return
+	}
+	if user.TOTPEnabled {
+		h.writeLoginChallenge(w, user)
+		return
+	}
+
+	response, err := h.issueTokens(user, "", false)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,171 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"supermarket-catalogue/internal/config"
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/oidc"
+	"supermarket-catalogue/internal/oidc/oidctest"
+)
+
+const testClientID = "catalogue"
+
+// newOIDCTestServer serves the API with single sign-on through a local
+// provider that logs in as user.
+func newOIDCTestServer(t *testing.T, user oidctest.User) *testServer {
+	t.Helper()
+	srv, _, err := oidctest.NewServer(testClientID, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	cfg := config.OIDCConfig{Issuer: srv.URL, ClientID: testClientID, ClientSecret: "secret"}
+	return newTestServer(t, handlers.Options{OIDC: oidc.New(cfg, testPublicURL+"/login/oidc/callback")})
+}
+
+// startOIDC begins a login and has the provider approve it. It returns the
+// flow cookie and the callback URL the provider sent the browser back to.
+func (s *testServer) startOIDC() (string, *url.URL) {
+	s.t.Helper()
+	rec := s.request("GET", "/login/oidc", nil)
+	expectStatus(s.t, rec, http.StatusFound)
+	var cookie string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "oidc_flow" {
+			cookie = c.Name + "=" + c.Value
+		}
+	}
+	if cookie == "" {
+		s.t.Fatal("login did not set the flow cookie")
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := browser.Get(rec.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		s.t.Fatalf("provider did not redirect back: %v", err)
+	}
+	return cookie, callback
+}
+
+// finishOIDC delivers the provider's redirect to the callback and returns
+// the query of the frontend URL it redirects to.
+func (s *testServer) finishOIDC(cookie string, callback *url.URL) url.Values {
+	s.t.Helper()
+	var headers []string
+	if cookie != "" {
+		headers = []string{"Cookie", cookie}
+	}
+	rec := s.request("GET", callback.RequestURI(), nil, headers...)
+	expectStatus(s.t, rec, http.StatusFound)
+	target, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return target.Query()
+}
+
+// loginOIDC logs in through the provider and returns the session.
+func (s *testServer) loginOIDC() *models.AuthResponse {
+	s.t.Helper()
+	q := s.finishOIDC(s.startOIDC())
+	if q.Get("sso_token") == "" {
+		s.t.Fatalf("single sign-on failed: %q", q.Get("sso_error"))
+	}
+	var resp models.AuthResponse
+	rec := s.request("POST", "/login/oidc/token", map[string]string{"token": q.Get("sso_token")})
+	decodeResponse(s.t, rec, http.StatusOK, &resp)
+	return &resp
+}
+
+func TestOIDCLoginCreatesAndLinksUser(t *testing.T) {
+	s := newOIDCTestServer(t, oidctest.User{Subject: "sub-1", Email: "olga@example.com", EmailVerified: true, Name: "Olga"})
+
+	session := s.loginOIDC()
+	var me models.User
+	decodeResponse(t, s.request("GET", "/me", nil, bearer(session.Token)...), http.StatusOK, &me)
+	if me.Email != "olga@example.com" || !me.EmailVerified {
+		t.Fatalf("signed in as %+v, want verified olga@example.com", me)
+	}
+
+	again := s.loginOIDC()
+	var second models.User
+	decodeResponse(t, s.request("GET", "/me", nil, bearer(again.Token)...), http.StatusOK, &second)
+	if second.ID != me.ID {
+		t.Fatalf("second login signed in as user %d, want %d", second.ID, me.ID)
+	}
+}
+
+func TestOIDCCallbackChecksFlow(t *testing.T) {
+	s := newOIDCTestServer(t, oidctest.User{Subject: "sub-1", Email: "olga@example.com", EmailVerified: true})
+
+	cookie, callback := s.startOIDC()
+	q := callback.Query()
+	q.Set("state", q.Get("state")+"x")
+	tampered := *callback
+	tampered.RawQuery = q.Encode()
+	if got := s.finishOIDC(cookie, &tampered); got.Get("sso_error") == "" || got.Get("sso_token") != "" {
+		t.Fatalf("callback with another state gave %v, want sso_error", got)
+	}
+
+	_, callback = s.startOIDC()
+	if got := s.finishOIDC("", callback); got.Get("sso_error") == "" || got.Get("sso_token") != "" {
+		t.Fatalf("callback without the flow cookie gave %v, want sso_error", got)
+	}
+
+	// The token from a completed login works once.
+	q = s.finishOIDC(s.startOIDC())
+	body := map[string]string{"token": q.Get("sso_token")}
+	expectStatus(t, s.request("POST", "/login/oidc/token", body), http.StatusOK)
+	expectStatus(t, s.request("POST", "/login/oidc/token", body), http.StatusBadRequest)
+}
+
+func TestOIDCRequiresVerifiedEmail(t *testing.T) {
+	s := newOIDCTestServer(t, oidctest.User{Subject: "sub-1", Email: adminEmail, EmailVerified: false})
+
+	if q := s.finishOIDC(s.startOIDC()); q.Get("sso_error") == "" || q.Get("sso_token") != "" {
+		t.Fatalf("login with an unverified address gave %v, want sso_error", q)
+	}
+	admin, err := s.stores.Users.GetUserByEmail(adminEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identities, err := s.stores.Identities.ListIdentities(admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 0 {
+		t.Fatalf("admin was linked to %v", identities)
+	}
+}
+
+func TestOIDCClaimsUnverifiedAccount(t *testing.T) {
+	s := newOIDCTestServer(t, oidctest.User{Subject: "sub-1", Email: "olga@example.com", EmailVerified: true})
+	// Someone registered the address before its owner signed in.
+	squatter := s.createUser("Squatter", "olga@example.com", "password123", models.RoleUser, false)
+	squatted := s.login("olga@example.com", "password123")
+
+	session := s.loginOIDC()
+	var me models.User
+	decodeResponse(t, s.request("GET", "/me", nil, bearer(session.Token)...), http.StatusOK, &me)
+	if me.ID != squatter.ID || !me.EmailVerified {
+		t.Fatalf("signed in as %+v, want verified user %d", me, squatter.ID)
+	}
+
+	// The password and sessions of the registration no longer work.
+	rec := s.request("POST", "/login", models.AuthRequest{Email: "olga@example.com", Password: "password123"})
+	expectStatus(t, rec, http.StatusUnauthorized)
+	expectStatus(t, s.request("GET", "/me", nil, bearer(squatted.Token)...), http.StatusUnauthorized)
+	rec = s.request("POST", "/token/refresh", map[string]string{"refresh_token": squatted.RefreshToken})
+	expectStatus(t, rec, http.StatusUnauthorized)
+}
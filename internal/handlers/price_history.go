@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type productHistoryResponse struct {
+	ProductID int                 `json:"product_id"`
+	History   []models.PricePoint `json:"history"`
+}
+
+type barcodeHistoryResponse struct {
+	Barcode string               `json:"barcode"`
+	Series  []models.PriceSeries `json:"series"`
+}
+
+// GetProductHistory returns the recorded prices of one product in
+// chronological order, optionally bounded by from and to.
+func (h *Handler) GetProductHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	from, to, err := parseHistoryRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.products.ProductHistory(id, from, to)
+	if errors.Is(err, repository.ErrNotFound) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(productHistoryResponse{ProductID: id, History: history})
+}
+
+// CompareHistoryByBarcode returns one price series per supermarket for every
+// product carrying the barcode.
+func (h *Handler) CompareHistoryByBarcode(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	code := vars["barcode"]
+	if code == "" {
+		http.Error(w, "barcode required", http.StatusBadRequest)
+		return
+	}
+	from, to, err := parseHistoryRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	series, err := h.products.BarcodeHistory(code, from, to)
+	if err != nil {
+		http.Error(w, "database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(series) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "no price history found for barcode"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(barcodeHistoryResponse{Barcode: code, Series: series})
+}
+
+// parseHistoryRange reads the from and to query parameters as RFC 3339
+// timestamps or YYYY-MM-DD dates. A date in to covers that whole day.
+func parseHistoryRange(r *http.Request) (from, to time.Time, err error) {
+	from = time.Unix(0, 0).UTC()
+	to = time.Now().UTC().Add(time.Minute)
+
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		if from, err = parseHistoryTime(raw, false); err != nil {
+			return from, to, fmt.Errorf("invalid from: %s", raw)
+		}
+	}
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		if to, err = parseHistoryTime(raw, true); err != nil {
+			return from, to, fmt.Errorf("invalid to: %s", raw)
+		}
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
+func parseHistoryTime(raw string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return t, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+	"time"
+)
+
+// personalData is everything stored about a user, as returned by
+// ExportPersonalData. Products are those the user created, with the price
+// timeline of each.
+type personalData struct {
+	ExportedAt          time.Time            `json:"exported_at"`
+	Profile             *models.User         `json:"profile"`
+	LinkedIdentities    []models.Identity    `json:"linked_identities"`
+	TwoFactor           twoFactorStatus      `json:"two_factor"`
+	Products            []exportedProduct    `json:"products"`
+	Supermarkets        []models.Supermarket `json:"supermarkets"`
+	Categories          []models.Category    `json:"categories"`
+	ManagedSupermarkets []models.Supermarket `json:"managed_supermarkets"`
+}
+
+type exportedProduct struct {
+	models.Product
+	Prices []models.PricePoint `json:"prices"`
+}
+
+// files splits the export into the documents of the ZIP format.
+func (d *personalData) files() []struct {
+	name string
+	data interface{}
+} {
+	return []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", struct {
+			ExportedAt time.Time    `json:"exported_at"`
+			Profile    *models.User `json:"profile"`
+		}{d.ExportedAt, d.Profile}},
+		{"linked_identities.json", d.LinkedIdentities},
+		{"two_factor.json", d.TwoFactor},
+		{"products.json", d.Products},
+		{"supermarkets.json", d.Supermarkets},
+		{"categories.json", d.Categories},
+		{"managed_supermarkets.json", d.ManagedSupermarkets},
+	}
+}
+
+// collectPersonalData gathers the export for user.
+func (h *Handler) collectPersonalData(user *models.User) (*personalData, error) {
+	now := time.Now().UTC()
+	data := &personalData{
+		ExportedAt:   now,
+		Profile:      user,
+		Products:     []exportedProduct{},
+		Supermarkets: []models.Supermarket{},
+		Categories:   []models.Category{},
+	}
+
+	var err error
+	if data.LinkedIdentities, err = h.identities.ListIdentities(user.ID); err != nil {
+		return nil, err
+	}
+
+	data.TwoFactor.Required = auth.MFARequired(user.Role)
+	totp, err := h.twoFactor.GetTOTP(user.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	if err == nil && totp.Enabled {
+		data.TwoFactor.Enabled = true
+		data.TwoFactor.RecoveryCodesLeft = totp.RecoveryCodesLeft
+	}
+
+	err = h.products.EachProduct(repository.ProductFilter{OwnerID: user.ID}, func(p models.Product) error {
+		prices, err := h.products.ProductHistory(p.ID, time.Time{}, now.Add(time.Second))
+		if err != nil {
+			return err
+		}
+		data.Products = append(data.Products, exportedProduct{Product: p, Prices: prices})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	supermarkets, err := h.supermarkets.ListSupermarkets()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range supermarkets {
+		if s.OwnerID == user.ID {
+			data.Supermarkets = append(data.Supermarkets, s)
+		}
+	}
+	if data.ManagedSupermarkets, err = h.supermarkets.ListManagedSupermarkets(user.ID); err != nil {
+		return nil, err
+	}
+
+	categories, err := h.categories.ListCategories()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		if c.OwnerID == user.ID {
+			data.Categories = append(data.Categories, c)
+		}
+	}
+	return data, nil
+}
+
+// ExportPersonalData returns everything stored about the current user, as
+// one JSON document (default) or, with format=zip, a ZIP archive of one
+// JSON file per kind of record. Secrets such as the password hash, the TOTP
+// secret and recovery codes are not included.
+func (h *Handler) ExportPersonalData(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		http.Error(w, `{"error": "invalid format: use json or zip"}`, http.StatusBadRequest)
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	data, err := h.collectPersonalData(user)
+	if err != nil {
+		log.Printf("exporting data of user %d: %v", user.ID, err)
+		http.Error(w, `{"error": "Failed to export data"}`, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("user %d exported their data", user.ID)
+
+	filename := fmt.Sprintf("personal-data-%d-%s", user.ID, data.ExportedAt.Format("20060102"))
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(data)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	zw := zip.NewWriter(w)
+	for _, f := range data.files() {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: data.ExportedAt})
+		if err == nil {
+			enc := json.NewEncoder(fw)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(f.data)
+		}
+		if err != nil {
+			log.Printf("exporting data of user %d: %v", user.ID, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("exporting data of user %d: %v", user.ID, err)
+	}
+}
+
+// eraseUser anonymises account id, ending its sessions first so no token
+// outlives the erasure.
+func (h *Handler) eraseUser(id int) error {
+	if err := h.tokens.RevokeUserTokens(id); err != nil {
+		return err
+	}
+	user, err := h.users.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if err := h.users.EraseUser(id); err != nil {
+		return err
+	}
+	if err := h.logins.ClearLoginAttempts(accountLoginKey(user.Email)); err != nil {
+		log.Printf("clearing failed logins of erased user %d: %v", id, err)
+	}
+	return nil
+}
+
+// EraseUser answers a data-subject request on behalf of a user: the account
+// is anonymised and disabled, while the catalogue records it owns stay.
+func (h *Handler) EraseUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.eraseUser(id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	log.Printf("user %d erased by user %s", id, r.Header.Get("X-User-ID"))
+	h.writeUser(w, id)
+}
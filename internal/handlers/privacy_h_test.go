@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+// exportedFiles returns the documents of the user's export in format.
+func exportedFiles(t *testing.T, s *testServer, token, format string) map[string]string {
+	t.Helper()
+	rec := s.request("GET", "/me/export?format="+format, nil, bearer(token)...)
+	expectStatus(t, rec, http.StatusOK)
+	if format == "json" {
+		return map[string]string{"export.json": rec.Body.String()}
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+func TestExportLeavesOutSecrets(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	u := enrollTwoFactor(t, s)
+	rec := loginTwoFactor(s, u.challenge(t, s), u.recoveryCodes[0])
+	var session models.AuthResponse
+	decodeResponse(t, rec, http.StatusOK, &session)
+
+	user, err := s.stores.Users.GetUserByEmail(u.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := &models.Product{Name: "Oat milk", Price: 1.5, OwnerID: user.ID}
+	if err := s.stores.Products.CreateProduct(product); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := append([]string{"$2a$", u.secret, session.RefreshToken}, u.recoveryCodes...)
+	for _, format := range []string{"json", "zip"} {
+		all := ""
+		for name, data := range exportedFiles(t, s, session.Token, format) {
+			for _, secret := range secrets {
+				if strings.Contains(data, secret) {
+					t.Errorf("%s export: %s contains secret %q", format, name, secret)
+				}
+			}
+			all += data
+		}
+		if !strings.Contains(all, u.email) || !strings.Contains(all, product.Name) {
+			t.Errorf("%s export lacks the profile or the product:\n%s", format, all)
+		}
+	}
+}
+
+func TestDeleteAccountErasesUser(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	user := s.createUser("Erin", "erin@example.com", "password123", models.RoleUser, true)
+	err := s.stores.Identities.LinkIdentity(&models.Identity{UserID: user.ID, Issuer: "https://idp.example.com", Subject: "sub-1", Email: user.Email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := &models.Product{Name: "Oat milk", Price: 1.5, OwnerID: user.ID}
+	if err := s.stores.Products.CreateProduct(product); err != nil {
+		t.Fatal(err)
+	}
+	session := s.login("erin@example.com", "password123")
+
+	expectStatus(t, s.request("DELETE", "/me", map[string]string{"password": "wrong"}, bearer(session.Token)...), http.StatusForbidden)
+	expectStatus(t, s.request("DELETE", "/me", map[string]string{"password": "password123"}, bearer(session.Token)...), http.StatusNoContent)
+
+	erased, err := s.stores.Users.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if erased.Name != models.ErasedUserName || strings.Contains(erased.Email, "erin") || !erased.Disabled {
+		t.Errorf("erased account = %+v, want anonymised and disabled", erased)
+	}
+	identities, err := s.stores.Identities.ListIdentities(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 0 {
+		t.Errorf("erased account is still linked to %v", identities)
+	}
+
+	expectStatus(t, s.request("GET", "/me", nil, bearer(session.Token)...), http.StatusUnauthorized)
+	rec := s.request("POST", "/token/refresh", map[string]string{"refresh_token": session.RefreshToken})
+	expectStatus(t, rec, http.StatusUnauthorized)
+	rec = s.request("POST", "/login", models.AuthRequest{Email: "erin@example.com", Password: "password123"})
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	// The catalogue keeps what the user contributed.
+	kept, err := s.stores.Products.GetProduct(product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.OwnerID != user.ID {
+		t.Errorf("owner of product = %d after erasure, want %d", kept.OwnerID, user.ID)
+	}
+}
+
+func TestEraseUserByAdmin(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	user := s.createUser("Erin", "erin@example.com", "password123", models.RoleUser, true)
+
+	var erased models.User
+	rec := s.request("POST", fmt.Sprintf("/admin/users/%d/erase", user.ID), nil, bearer(admin.Token)...)
+	decodeResponse(t, rec, http.StatusOK, &erased)
+	if erased.Name != models.ErasedUserName || strings.Contains(erased.Email, "erin") {
+		t.Errorf("erased account = %+v, want anonymised", erased)
+	}
+	rec = s.request("POST", fmt.Sprintf("/admin/users/%d/erase", user.ID), nil, bearer(admin.Token)...)
+	expectStatus(t, rec, http.StatusNotFound)
+
+	// The last admin cannot erase their own account.
+	expectStatus(t, s.request("DELETE", "/me", map[string]string{"password": adminPassword}, bearer(admin.Token)...), http.StatusConflict)
+}
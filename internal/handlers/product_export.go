@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+	"supermarket-catalogue/internal/xlsx"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// exportColumns are the importColumns followed by readOnlyColumns, which
+// import ignores.
+var exportColumns = append(append([]string{}, importColumns...), readOnlyColumns...)
+
+// exportWriteTimeout bounds how long the client may take to accept each
+// row. The deadline moves on with every row, so a large export can run
+// past the server's write timeout while a stalled client is still cut off.
+const exportWriteTimeout = time.Minute
+
+// formulaPrefixes are the leading characters that make a spreadsheet
+// treat a cell as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// spreadsheetText quotes user-supplied text that a spreadsheet would
+// otherwise evaluate as a formula. CSV import undoes it; see csvText.
+func spreadsheetText(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// exportRow returns p's values in exportColumns order for CSV and XLSX.
+// Zero optional fields are left empty so they read as "not given" on
+// re-import.
+func exportRow(p models.Product) []interface{} {
+	optionalInt := func(v int) interface{} {
+		if v == 0 {
+			return nil
+		}
+		return v
+	}
+	optionalFloat := func(v float64) interface{} {
+		if v == 0 {
+			return nil
+		}
+		return v
+	}
+	return []interface{}{
+		optionalInt(p.SupermarketID), spreadsheetText(p.Barcode), spreadsheetText(p.Name), p.Price, p.Stock,
+		spreadsheetText(p.Unit), optionalFloat(p.UnitPrice), optionalInt(p.CategoryID), spreadsheetText(p.Image),
+		p.ID, p.LastUpdated.UTC().Format(time.RFC3339),
+	}
+}
+
+// productExporter writes one product at a time in a given format.
+type productExporter interface {
+	Write(p models.Product) error
+	Close() error
+}
+
+type csvExporter struct{ w *csv.Writer }
+
+func (e *csvExporter) Write(p models.Product) error {
+	record := make([]string, 0, len(exportColumns))
+	for _, v := range exportRow(p) {
+		switch v := v.(type) {
+		case nil:
+			record = append(record, "")
+		case float64:
+			record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+		case int:
+			record = append(record, strconv.Itoa(v))
+		default:
+			record = append(record, v.(string))
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// exportRecord is the JSONL form of exportRow.
+type exportRecord struct {
+	SupermarketID int       `json:"supermarket_id,omitempty"`
+	Barcode       string    `json:"barcode,omitempty"`
+	Name          string    `json:"name"`
+	Price         float64   `json:"price"`
+	Stock         int       `json:"stock"`
+	Unit          string    `json:"unit,omitempty"`
+	UnitPrice     float64   `json:"unit_price,omitempty"`
+	CategoryID    int       `json:"category_id,omitempty"`
+	Image         string    `json:"image,omitempty"`
+	ID            int       `json:"id"`
+	LastUpdated   time.Time `json:"last_updated"`
+}
+
+type jsonlExporter struct{ enc *json.Encoder }
+
+func (e *jsonlExporter) Write(p models.Product) error {
+	return e.enc.Encode(exportRecord{
+		SupermarketID: p.SupermarketID,
+		Barcode:       p.Barcode,
+		Name:          p.Name,
+		Price:         p.Price,
+		Stock:         p.Stock,
+		Unit:          p.Unit,
+		UnitPrice:     p.UnitPrice,
+		CategoryID:    p.CategoryID,
+		Image:         p.Image,
+		ID:            p.ID,
+		LastUpdated:   p.LastUpdated.UTC(),
+	})
+}
+
+func (e *jsonlExporter) Close() error { return nil }
+
+type xlsxExporter struct{ w *xlsx.Writer }
+
+func (e *xlsxExporter) Write(p models.Product) error { return e.w.WriteRow(exportRow(p)...) }
+func (e *xlsxExporter) Close() error                 { return e.w.Close() }
+
+// newProductExporter sets the response headers for format and writes any
+// header row.
+func newProductExporter(w http.ResponseWriter, format string) (productExporter, error) {
+	header := make([]interface{}, len(exportColumns))
+	for i, col := range exportColumns {
+		header[i] = col
+	}
+
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+		cw := csv.NewWriter(w)
+		return &csvExporter{w: cw}, cw.Write(exportColumns)
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="products.jsonl"`)
+		return &jsonlExporter{enc: json.NewEncoder(w)}, nil
+	case "xlsx":
+		w.Header().Set("Content-Type", xlsx.ContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="products.xlsx"`)
+		xw, err := xlsx.NewWriter(w, "Products")
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxExporter{w: xw}, xw.WriteRow(header...)
+	}
+	return nil, errors.New("invalid format: use csv, jsonl or xlsx")
+}
+
+// ExportProducts streams every product matching the GetProducts filters as
+// CSV (default), JSONL or XLSX.
+func (h *Handler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseProductFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.exportProducts(w, r, filter)
+}
+
+// ExportSupermarketProducts streams one supermarket's products; it accepts
+// the same parameters as ExportProducts.
+func (h *Handler) ExportSupermarketProducts(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.supermarkets.GetSupermarket(id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filter, err := parseProductFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.SupermarketID = id
+	h.exportProducts(w, r, filter)
+}
+
+func (h *Handler) exportProducts(w http.ResponseWriter, r *http.Request, filter repository.ProductFilter) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "jsonl" && format != "xlsx" {
+		http.Error(w, "invalid format: use csv, jsonl or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+
+	exporter, err := newProductExporter(w, format)
+	if err == nil {
+		err = h.products.EachProduct(filter, func(p models.Product) error {
+			rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+			return exporter.Write(p)
+		})
+	}
+	if err == nil {
+		err = exporter.Close()
+	}
+	if err != nil {
+		// The status line has usually gone out already; all that is left
+		// is to cut the download short.
+		log.Printf("product export failed: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+// seedExport adds products worth exporting, one of them named like a
+// spreadsheet formula.
+func seedExport(t *testing.T, s *testServer, adminToken string) []models.Product {
+	t.Helper()
+	sm := s.createSupermarket(adminToken, "Shop")
+	var products []models.Product
+	for _, p := range []models.Product{
+		{Name: "Milk", Price: 1.2, Stock: 3, Unit: "l", UnitPrice: 1.2, Barcode: "4000001", SupermarketID: sm.ID},
+		{Name: "=HYPERLINK(\"http://evil.example\")", Price: 2, Barcode: "-4000002", SupermarketID: sm.ID},
+	} {
+		var created models.Product
+		decodeResponse(t, s.request("POST", "/products", p, bearer(adminToken)...), http.StatusCreated, &created)
+		products = append(products, created)
+	}
+	return products
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{"csv", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			s := newTestServer(t, handlers.Options{})
+			admin := s.login(adminEmail, adminPassword)
+			products := seedExport(t, s, admin.Token)
+
+			rec := s.request("GET", "/admin/products/export?format="+format, nil, bearer(admin.Token)...)
+			expectStatus(t, rec, http.StatusOK)
+			exported := rec.Body.String()
+
+			rec = s.request("POST", "/admin/products/import?format="+format, exported, bearer(admin.Token)...)
+			var report handlers.ImportReport
+			decodeResponse(t, rec, http.StatusOK, &report)
+			if report.Updated != len(products) || report.Created != 0 || report.Rejected != 0 {
+				t.Fatalf("re-importing the export gave %+v, want %d updates", report, len(products))
+			}
+			for _, want := range products {
+				got, err := s.stores.Products.GetProduct(want.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Name != want.Name || got.Barcode != want.Barcode || got.Price != want.Price ||
+					got.Stock != want.Stock || got.Unit != want.Unit || got.UnitPrice != want.UnitPrice {
+					t.Errorf("product after round trip = %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExportEscapesFormulas(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	products := seedExport(t, s, admin.Token)
+
+	// The per-supermarket export is public.
+	rec := s.request("GET", fmt.Sprintf("/supermarkets/%d/products/export", products[0].SupermarketID), nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	if !strings.Contains(body, `'=HYPERLINK`) || !strings.Contains(body, "'-4000002") {
+		t.Errorf("CSV export does not quote formula-like cells:\n%s", body)
+	}
+
+	rec = s.request("GET", "/admin/products/export?format=jsonl", nil, bearer(admin.Token)...)
+	expectStatus(t, rec, http.StatusOK)
+	if strings.Contains(rec.Body.String(), `'=`) {
+		t.Errorf("JSONL export quotes text:\n%s", rec.Body)
+	}
+}
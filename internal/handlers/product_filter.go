@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"supermarket-catalogue/internal/repository"
+)
+
+// parseProductFilter reads the filter and sort parameters accepted by
+// GetProducts:
+//
+//	name           case-insensitive substring of the product name
+//	supermarket_id exact supermarket
+//	category_id    exact category
+//	barcode        exact barcode
+//	min_price      lowest price, inclusive
+//	max_price      highest price, inclusive
+//	in_stock       true to drop products with no stock
+//	sort           price, unit_price, name or last_updated
+//	order          asc (default) or desc; without sort it orders by id
+func parseProductFilter(r *http.Request) (repository.ProductFilter, error) {
+	q := r.URL.Query()
+	var f repository.ProductFilter
+
+	f.Name = strings.TrimSpace(q.Get("name"))
+	f.Barcode = strings.TrimSpace(q.Get("barcode"))
+
+	for _, param := range []struct {
+		key string
+		dst *int
+	}{{"supermarket_id", &f.SupermarketID}, {"category_id", &f.CategoryID}} {
+		raw := q.Get(param.key)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.Atoi(raw)
+		if err != nil || id < 1 {
+			return f, fmt.Errorf("invalid %s", param.key)
+		}
+		*param.dst = id
+	}
+
+	if raw := q.Get("min_price"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 {
+			return f, fmt.Errorf("invalid min_price")
+		}
+		f.MinPrice = &v
+	}
+	if raw := q.Get("max_price"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 {
+			return f, fmt.Errorf("invalid max_price")
+		}
+		if f.MinPrice != nil && v < *f.MinPrice {
+			return f, fmt.Errorf("max_price is below min_price")
+		}
+		f.MaxPrice = &v
+	}
+
+	if raw := q.Get("in_stock"); raw != "" {
+		inStock, err := strconv.ParseBool(raw)
+		if err != nil {
+			return f, fmt.Errorf("invalid in_stock")
+		}
+		f.InStock = inStock
+	}
+
+	if sort := q.Get("sort"); sort != "" {
+		valid := false
+		for _, key := range repository.ProductSortKeys {
+			valid = valid || key == sort
+		}
+		if !valid {
+			return f, fmt.Errorf("invalid sort: use %s", strings.Join(repository.ProductSortKeys, ", "))
+		}
+		f.Sort = sort
+	}
+	switch strings.ToLower(q.Get("order")) {
+	case "", "asc":
+	case "desc":
+		f.Desc = true
+	default:
+		return f, fmt.Errorf("invalid order: use asc or desc")
+	}
+
+	return f, nil
+}
+
+// parsePagination reads the page and limit query parameters shared by the
+// listing endpoints, defaulting to the first page of ten.
+func parsePagination(r *http.Request) (page, limit int, p repository.Page) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ = strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 {
+		limit = 10
+	}
+	return page, limit, repository.Page{Limit: limit, Offset: (page - 1) * limit}
+}
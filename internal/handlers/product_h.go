@@ -1,77 +1,27 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"supermarket-catalogue/internal/middleware"
 	"supermarket-catalogue/internal/models"
-	database "supermarket-catalogue/internal/repository"
+	"supermarket-catalogue/internal/policy"
+	"supermarket-catalogue/internal/repository"
 
 	"github.com/gorilla/mux"
 )
 
-func GetProducts(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	if limit < 1 {
-		limit = 10
-	}
-	offset := (page - 1) * limit
-
-	rows, err := database.DB.Query(`
-		SELECT id, name, price, stock, image, category_id, owner_id, supermarket_id, barcode, unit, unit_price, last_updated, created_at
-		FROM products
-		ORDER BY id
-		LIMIT $1 OFFSET $2
-	`, limit, offset)
+func (h *Handler) GetProducts(w http.ResponseWriter, r *http.Request) {
+	page, limit, window := parsePagination(r)
+	filter, err := parseProductFilter(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer rows.Close()
-
-	var products []models.Product
-	for rows.Next() {
-		var p models.Product
-		var image, barcode, unit sql.NullString
-		var unitPrice sql.NullFloat64
-		var lastUpdated, createdAt sql.NullTime
-		var ownerID, supermarketID sql.NullInt64
-
-		if err := rows.Scan(
-			&p.ID, &p.Name, &p.Price, &p.Stock,
-			&image, &p.CategoryID, &ownerID, &supermarketID,
-			&barcode, &unit, &unitPrice, &lastUpdated, &createdAt,
-		); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		p.Image = image.String
-		p.Barcode = barcode.String
-		p.Unit = unit.String
-		if unitPrice.Valid {
-			p.UnitPrice = unitPrice.Float64
-		}
-		if lastUpdated.Valid {
-			p.LastUpdated = lastUpdated.Time
-		}
-		if ownerID.Valid {
-			p.OwnerID = int(ownerID.Int64)
-		}
-		if supermarketID.Valid {
-			p.SupermarketID = int(supermarketID.Int64)
-		}
 
-		products = append(products, p)
-	}
-
-	var total int
-	err = database.DB.QueryRow(`SELECT COUNT(*) FROM products`).Scan(&total)
+	products, total, err := h.products.ListProducts(filter, window)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -87,7 +37,7 @@ func GetProducts(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-func CreateProduct(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	var product models.Product
 	err := json.NewDecoder(r.Body).Decode(&product)
 	if err != nil {
@@ -95,27 +45,17 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		INSERT INTO products (name, price, stock, image, category_id, owner_id, supermarket_id, barcode, unit, unit_price)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING id
-	`
-
-	err = database.DB.QueryRow(
-		query,
-		product.Name,
-		product.Price,
-		product.Stock,
-		product.Image,
-		product.CategoryID,
-		product.OwnerID,
-		product.SupermarketID,
-		product.Barcode,
-		product.Unit,
-		product.UnitPrice,
-	).Scan(&product.ID)
+	if !h.validateProductCategory(w, product.CategoryID) {
+		return
+	}
+	if !h.canManageSupermarket(w, r, product.SupermarketID) {
+		return
+	}
+	if product.OwnerID == 0 || !middleware.Can(r, policy.ProductAnySupermarket) {
+		product.OwnerID, _ = strconv.Atoi(r.Header.Get("X-User-ID"))
+	}
 
-	if err != nil {
+	if err := h.products.CreateProduct(&product); err != nil {
 		http.Error(w, "Failed to create product: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -125,7 +65,7 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(product)
 }
 
-func GetProductByID(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetProductByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
@@ -135,27 +75,7 @@ func GetProductByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		SELECT id, name, price, stock, image, category_id, owner_id, supermarket_id, barcode, unit, unit_price, last_updated
-		FROM products 
-		WHERE id = $1
-	`
-
-	var p models.Product
-	var image sql.NullString
-	var barcode sql.NullString
-	var unit sql.NullString
-	var unitPrice sql.NullFloat64
-	var lastUpdated sql.NullTime
-	var ownerID sql.NullInt64
-	var supermarketID sql.NullInt64
-
-	err = database.DB.QueryRow(query, id).Scan(
-		&p.ID, &p.Name, &p.Price, &p.Stock,
-		&image, &p.CategoryID, &ownerID, &supermarketID,
-		&barcode, &unit, &unitPrice, &lastUpdated,
-	)
-
+	p, err := h.products.GetProduct(id)
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNotFound)
@@ -165,22 +85,6 @@ func GetProductByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p.Image = image.String
-	p.Barcode = barcode.String
-	p.Unit = unit.String
-	if unitPrice.Valid {
-		p.UnitPrice = unitPrice.Float64
-	}
-	if lastUpdated.Valid {
-		p.LastUpdated = lastUpdated.Time
-	}
-	if ownerID.Valid {
-		p.OwnerID = int(ownerID.Int64)
-	}
-	if supermarketID.Valid {
-		p.SupermarketID = int(supermarketID.Int64)
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(p)
 }
@@ -193,7 +97,7 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func UpdateProduct(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
@@ -210,19 +114,26 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-        UPDATE products 
-        SET name = $1, price = $2, stock = $3, image = $4, category_id = $5, owner_id = $6, supermarket_id = $7, barcode = $8, unit = $9, unit_price = $10
-        WHERE id = $11
-        RETURNING id
-    `
+	if !h.validateProductCategory(w, product.CategoryID) {
+		return
+	}
 
-	err = database.DB.QueryRow(query,
-		product.Name, product.Price, product.Stock, product.Image,
-		product.CategoryID, product.OwnerID, product.SupermarketID, product.Barcode, product.Unit, product.UnitPrice, id,
-	).Scan(&product.ID)
+	// Managers may neither touch another store's product nor move one of
+	// theirs to a store they do not manage.
+	existing, ok := h.productForWrite(w, r, id)
+	if !ok {
+		return
+	}
+	if !h.canManageSupermarket(w, r, product.SupermarketID) {
+		return
+	}
+	if !middleware.Can(r, policy.ProductAnySupermarket) {
+		product.OwnerID = existing.OwnerID
+	}
 
-	if err != nil {
+	product.ID = id
+	err = h.products.UpdateProduct(&product)
+	if errors.Is(err, repository.ErrNotFound) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -230,13 +141,16 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if err != nil {
+		http.Error(w, "Failed to update product: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	product.ID = id
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(product)
 }
 
-func DeleteProduct(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 
@@ -246,15 +160,12 @@ func DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `DELETE FROM products WHERE id = $1`
-	result, err := database.DB.Exec(query, id)
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	if _, ok := h.productForWrite(w, r, id); !ok {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	err = h.products.DeleteProduct(id)
+	if errors.Is(err, repository.ErrNotFound) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -262,6 +173,28 @@ func DeleteProduct(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// validateProductCategory rejects a product whose category_id does not point
+// at a stored category. A zero category_id leaves the product uncategorised.
+func (h *Handler) validateProductCategory(w http.ResponseWriter, categoryID int) bool {
+	if categoryID == 0 {
+		return true
+	}
+	exists, err := h.categories.CategoryExists(categoryID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.Error(w, "Category not found", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
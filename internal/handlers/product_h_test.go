@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+func TestListProductsOrder(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	sm := s.createSupermarket(admin.Token, "Shop")
+	for _, p := range []models.Product{
+		{Name: "Milk", Price: 2, SupermarketID: sm.ID},
+		{Name: "Bread", Price: 3, SupermarketID: sm.ID},
+		{Name: "Eggs", Price: 1, SupermarketID: sm.ID},
+	} {
+		expectStatus(t, s.request("POST", "/products", p, bearer(admin.Token)...), http.StatusCreated)
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Milk", "Bread", "Eggs"}},
+		{"?order=desc", []string{"Eggs", "Bread", "Milk"}},
+		{"?sort=price", []string{"Eggs", "Milk", "Bread"}},
+		{"?sort=price&order=desc", []string{"Bread", "Milk", "Eggs"}},
+	} {
+		var page struct {
+			Products []models.Product `json:"products"`
+			Total    int              `json:"total"`
+		}
+		decodeResponse(t, s.request("GET", "/products"+tc.query, nil), http.StatusOK, &page)
+		var got []string
+		for _, p := range page.Products {
+			got = append(got, p.Name)
+		}
+		if page.Total != len(tc.want) || len(got) != len(tc.want) {
+			t.Fatalf("GET /products%s = %v, want %v", tc.query, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("GET /products%s = %v, want %v", tc.query, got, tc.want)
+				break
+			}
+		}
+	}
+
+	expectStatus(t, s.request("GET", "/products?order=down", nil), http.StatusBadRequest)
+}
@@ -0,0 +1,466 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+// maxImportSize caps the body of an import request.
+const maxImportSize = 32 << 20
+
+// importColumns lists the fields an import row may carry. supermarket_id,
+// barcode, name and price are required for new products; on update, fields
+// left out of a row keep their stored value.
+var importColumns = []string{"supermarket_id", "barcode", "name", "price", "stock", "unit", "unit_price", "category_id", "image"}
+
+// readOnlyColumns are exported so that an export can be imported back
+// unchanged, but their values are ignored: the product is found by
+// (supermarket_id, barcode) and last_updated is set on write.
+var readOnlyColumns = []string{"id", "last_updated"}
+
+// importRow is one parsed line of an import file. Nil fields were not given.
+type importRow struct {
+	SupermarketID *int     `json:"supermarket_id"`
+	Barcode       *string  `json:"barcode"`
+	Name          *string  `json:"name"`
+	Price         *float64 `json:"price"`
+	Stock         *int     `json:"stock"`
+	Unit          *string  `json:"unit"`
+	UnitPrice     *float64 `json:"unit_price"`
+	CategoryID    *int     `json:"category_id"`
+	Image         *string  `json:"image"`
+
+	// ID and LastUpdated accept the readOnlyColumns of a JSONL export.
+	ID          json.RawMessage `json:"id"`
+	LastUpdated json.RawMessage `json:"last_updated"`
+}
+
+// ImportResult reports what happened to one line of an import file.
+type ImportResult struct {
+	Line          int      `json:"line"`
+	Status        string   `json:"status"`
+	ProductID     int      `json:"product_id,omitempty"`
+	SupermarketID int      `json:"supermarket_id,omitempty"`
+	Barcode       string   `json:"barcode,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun   bool           `json:"dry_run"`
+	Created  int            `json:"created"`
+	Updated  int            `json:"updated"`
+	Rejected int            `json:"rejected"`
+	Rows     []ImportResult `json:"rows"`
+}
+
+// parsedRow pairs a decoded row with its line number, or the reason it could
+// not be decoded.
+type parsedRow struct {
+	line int
+	row  importRow
+	err  error
+}
+
+// ImportProducts upserts products by (supermarket_id, barcode) from a CSV
+// file with a header line or from JSONL, one object per line. Every row is
+// validated and reported as created, updated or rejected; with dry_run=true
+// nothing is written.
+func (h *Handler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	format, err := importFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportSize)
+	var rows []parsedRow
+	if format == "csv" {
+		rows, err = parseImportCSV(body)
+	} else {
+		rows, err = parseImportJSONL(body)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ownerID, _ := strconv.Atoi(r.Header.Get("X-User-ID"))
+	report, err := h.importRows(rows, ownerID, dryRun)
+	if err != nil {
+		http.Error(w, "Database error, nothing was imported: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// importFormat picks csv or jsonl from the format parameter, falling back to
+// the Content-Type header.
+func importFormat(r *http.Request) (string, error) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		switch ct := r.Header.Get("Content-Type"); {
+		case strings.HasPrefix(ct, "text/csv"):
+			format = "csv"
+		case strings.HasPrefix(ct, "application/x-ndjson"), strings.HasPrefix(ct, "application/jsonl"):
+			format = "jsonl"
+		}
+	}
+	if format != "csv" && format != "jsonl" {
+		return "", fmt.Errorf("unknown import format: set format=csv or format=jsonl")
+	}
+	return format, nil
+}
+
+func parseImportCSV(body io.Reader) ([]parsedRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty import file")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %v", err)
+	}
+	for i, col := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(col))
+		if err := checkImportColumn(header[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []parsedRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, parsedRow{line: parseErr.StartLine, err: parseErr.Err})
+				continue
+			}
+			return nil, err
+		}
+		if len(record) != len(header) {
+			rows = append(rows, parsedRow{line: line, err: fmt.Errorf("expected %d fields, got %d", len(header), len(record))})
+			continue
+		}
+		row, err := csvImportRow(header, record)
+		rows = append(rows, parsedRow{line: line, row: row, err: err})
+	}
+	return rows, nil
+}
+
+// checkImportColumn rejects a header that is neither one of importColumns
+// nor one of the ignored readOnlyColumns.
+func checkImportColumn(name string) error {
+	for _, col := range exportColumns {
+		if col == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown column %q: use %s", name, strings.Join(importColumns, ", "))
+}
+
+// csvImportRow converts one record. Empty cells are treated as not given,
+// and text cells escaped by spreadsheetText are restored.
+func csvImportRow(header, record []string) (importRow, error) {
+	var row importRow
+	for i, col := range header {
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+		var err error
+		switch col {
+		case "supermarket_id":
+			row.SupermarketID, err = parseImportInt(col, value)
+		case "category_id":
+			row.CategoryID, err = parseImportInt(col, value)
+		case "stock":
+			row.Stock, err = parseImportInt(col, value)
+		case "price":
+			row.Price, err = parseImportFloat(col, value)
+		case "unit_price":
+			row.UnitPrice, err = parseImportFloat(col, value)
+		case "barcode":
+			row.Barcode = csvText(value)
+		case "name":
+			row.Name = csvText(value)
+		case "unit":
+			row.Unit = csvText(value)
+		case "image":
+			row.Image = csvText(value)
+		}
+		if err != nil {
+			return row, err
+		}
+	}
+	return row, nil
+}
+
+// csvText undoes spreadsheetText.
+func csvText(value string) *string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		value = value[1:]
+	}
+	return &value
+}
+
+func parseImportInt(col, value string) (*int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", col)
+	}
+	return &v, nil
+}
+
+func parseImportFloat(col, value string) (*float64, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", col)
+	}
+	return &v, nil
+}
+
+func parseImportJSONL(body io.Reader) ([]parsedRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var rows []parsedRow
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var row importRow
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&row)
+		if err != nil {
+			err = fmt.Errorf("invalid json: %v", err)
+		}
+		rows = append(rows, parsedRow{line: line, row: row, err: err})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid jsonl: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("empty import file")
+	}
+	return rows, nil
+}
+
+// importRows validates each row and, unless dryRun is set, creates or
+// updates its product. A row whose (supermarket_id, barcode) already
+// appeared earlier in the file is rejected. The products the file touches
+// are loaded up front and written in one transaction, so a failed import
+// changes nothing.
+func (h *Handler) importRows(rows []parsedRow, ownerID int, dryRun bool) (*ImportReport, error) {
+	supermarkets, err := h.supermarkets.ListSupermarkets()
+	if err != nil {
+		return nil, err
+	}
+	knownSupermarkets := map[int]bool{}
+	for _, s := range supermarkets {
+		knownSupermarkets[s.ID] = true
+	}
+	categories, err := h.categories.ListCategories()
+	if err != nil {
+		return nil, err
+	}
+	knownCategories := map[int]bool{}
+	for _, c := range categories {
+		knownCategories[c.ID] = true
+	}
+
+	barcodes := []string{}
+	for i := range rows {
+		rows[i].row.trim()
+		if b := rows[i].row.Barcode; rows[i].err == nil && b != nil {
+			barcodes = append(barcodes, *b)
+		}
+	}
+	existing := map[string]models.Product{}
+	err = h.products.EachProduct(repository.ProductFilter{Barcodes: barcodes}, func(p models.Product) error {
+		if key := importKey(p.SupermarketID, p.Barcode); existing[key].ID == 0 {
+			existing[key] = p
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: dryRun, Rows: []ImportResult{}}
+	seen := map[string]int{}
+	// writes holds the product to save for each accepted row of the report.
+	writes := map[int]*models.Product{}
+	for _, parsed := range rows {
+		res := ImportResult{Line: parsed.line}
+		if parsed.err != nil {
+			res.Errors = []string{parsed.err.Error()}
+		} else {
+			row := parsed.row
+			if row.SupermarketID != nil {
+				res.SupermarketID = *row.SupermarketID
+			}
+			if row.Barcode != nil {
+				res.Barcode = *row.Barcode
+			}
+			res.Errors = validateImportRow(row, knownSupermarkets, knownCategories)
+
+			key := importKey(res.SupermarketID, res.Barcode)
+			if first, dup := seen[key]; dup && len(res.Errors) == 0 {
+				res.Errors = append(res.Errors, fmt.Sprintf("duplicate of line %d", first))
+			}
+			if len(res.Errors) == 0 {
+				// Only an accepted row claims its key, so a rejected row
+				// does not turn a later correction into a duplicate.
+				if p := planImportRow(row, existing, ownerID, &res); p != nil {
+					seen[key] = parsed.line
+					writes[len(report.Rows)] = p
+				}
+			}
+		}
+
+		switch {
+		case len(res.Errors) > 0:
+			res.Status = "rejected"
+			report.Rejected++
+		case res.Status == "created":
+			report.Created++
+		default:
+			report.Updated++
+		}
+		report.Rows = append(report.Rows, res)
+	}
+
+	if dryRun || len(writes) == 0 {
+		return report, nil
+	}
+	products := make([]*models.Product, 0, len(writes))
+	for i := range report.Rows {
+		if p, ok := writes[i]; ok {
+			products = append(products, p)
+		}
+	}
+	if err := h.products.SaveProducts(products); err != nil {
+		return nil, err
+	}
+	for i, p := range writes {
+		report.Rows[i].ProductID = p.ID
+	}
+	return report, nil
+}
+
+func importKey(supermarketID int, barcode string) string {
+	return strconv.Itoa(supermarketID) + "/" + barcode
+}
+
+func validateImportRow(row importRow, supermarkets, categories map[int]bool) []string {
+	var problems []string
+	if row.SupermarketID == nil {
+		problems = append(problems, "supermarket_id is required")
+	} else if !supermarkets[*row.SupermarketID] {
+		problems = append(problems, "supermarket not found")
+	}
+	if row.Barcode == nil || *row.Barcode == "" {
+		problems = append(problems, "barcode is required")
+	}
+	if row.Name != nil && *row.Name == "" {
+		problems = append(problems, "name must not be empty")
+	}
+	if row.Price != nil && *row.Price < 0 {
+		problems = append(problems, "price must not be negative")
+	}
+	if row.UnitPrice != nil && *row.UnitPrice < 0 {
+		problems = append(problems, "unit_price must not be negative")
+	}
+	if row.Stock != nil && *row.Stock < 0 {
+		problems = append(problems, "stock must not be negative")
+	}
+	if row.CategoryID != nil && *row.CategoryID != 0 && !categories[*row.CategoryID] {
+		problems = append(problems, "category not found")
+	}
+	return problems
+}
+
+// planImportRow applies a valid row on top of the stored product for its
+// (supermarket_id, barcode), or starts a new one, recording the outcome in
+// res. It returns the product to save, or nil if the row is rejected.
+func planImportRow(row importRow, existing map[string]models.Product, ownerID int, res *ImportResult) *models.Product {
+	p, found := existing[importKey(*row.SupermarketID, *row.Barcode)]
+	if found {
+		res.Status = "updated"
+		res.ProductID = p.ID
+	} else {
+		if row.Name == nil {
+			res.Errors = append(res.Errors, "name is required for new products")
+		}
+		if row.Price == nil {
+			res.Errors = append(res.Errors, "price is required for new products")
+		}
+		if len(res.Errors) > 0 {
+			return nil
+		}
+		p.OwnerID = ownerID
+		res.Status = "created"
+	}
+	row.apply(&p)
+	return &p
+}
+
+// trim drops surrounding spaces from the text fields, as CSV cells are
+// trimmed, so " 123 " matches the stored barcode 123.
+func (row *importRow) trim() {
+	for _, field := range []*string{row.Barcode, row.Name, row.Unit, row.Image} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+}
+
+func (row importRow) apply(p *models.Product) {
+	p.SupermarketID = *row.SupermarketID
+	p.Barcode = *row.Barcode
+	if row.Name != nil {
+		p.Name = *row.Name
+	}
+	if row.Price != nil {
+		p.Price = *row.Price
+	}
+	if row.Stock != nil {
+		p.Stock = *row.Stock
+	}
+	if row.Unit != nil {
+		p.Unit = *row.Unit
+	}
+	if row.UnitPrice != nil {
+		p.UnitPrice = *row.UnitPrice
+	}
+	if row.CategoryID != nil {
+		p.CategoryID = *row.CategoryID
+	}
+	if row.Image != nil {
+		p.Image = *row.Image
+	}
+}
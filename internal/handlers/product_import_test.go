@@ -0,0 +1,97 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+)
+
+// importCSV posts lines, after a header, to the admin import.
+func (s *testServer) importCSV(token, query string, lines ...string) *handlers.ImportReport {
+	s.t.Helper()
+	body := "supermarket_id,barcode,name,price\n" + strings.Join(lines, "\n") + "\n"
+	var report handlers.ImportReport
+	rec := s.request("POST", "/admin/products/import?format=csv"+query, body, bearer(token)...)
+	decodeResponse(s.t, rec, http.StatusOK, &report)
+	return &report
+}
+
+// offers returns how many stores list barcode.
+func (s *testServer) offers(barcode string) int {
+	s.t.Helper()
+	offers, err := s.stores.Products.OffersByBarcode(barcode)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return len(offers)
+}
+
+func TestImportDryRunWritesNothing(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	sm := s.createSupermarket(admin.Token, "Shop")
+	row := fmt.Sprintf("%d,4000001,Milk,1.2", sm.ID)
+
+	report := s.importCSV(admin.Token, "&dry_run=true", row)
+	if !report.DryRun || report.Created != 1 || report.Rows[0].Status != "created" {
+		t.Fatalf("dry run = %+v, want one row to create", report)
+	}
+	if n := s.offers("4000001"); n != 0 {
+		t.Fatalf("dry run wrote %d products", n)
+	}
+
+	report = s.importCSV(admin.Token, "", row)
+	if report.DryRun || report.Created != 1 || report.Rows[0].ProductID == 0 {
+		t.Fatalf("import = %+v, want one created product", report)
+	}
+	if n := s.offers("4000001"); n != 1 {
+		t.Fatalf("import wrote %d products, want 1", n)
+	}
+
+	report = s.importCSV(admin.Token, "", fmt.Sprintf("%d,4000001,,1.5", sm.ID))
+	if report.Updated != 1 || report.Created != 0 {
+		t.Fatalf("second import = %+v, want one update", report)
+	}
+}
+
+func TestImportRejectsBadRows(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	admin := s.login(adminEmail, adminPassword)
+	sm := s.createSupermarket(admin.Token, "Shop")
+
+	report := s.importCSV(admin.Token, "",
+		"999,4000001,Milk,1.2",                    // line 2: unknown supermarket
+		fmt.Sprintf("%d,,Milk,1.2", sm.ID),        // line 3: no barcode
+		fmt.Sprintf("%d,4000001,Milk,-1", sm.ID),  // line 4: negative price
+		fmt.Sprintf("%d,4000001,Milk,abc", sm.ID), // line 5: price is not a number
+		fmt.Sprintf("%d,4000001,,1.2", sm.ID),     // line 6: new product without a name
+		fmt.Sprintf("%d,4000001,Milk,1.2", sm.ID), // line 7: the corrected row
+		fmt.Sprintf("%d,4000001,Milk,1.3", sm.ID), // line 8: duplicate of line 7
+	)
+	if report.Rejected != 6 || report.Created != 1 {
+		t.Fatalf("import = %+v, want 6 rejected and 1 created", report)
+	}
+	for i, res := range report.Rows {
+		wantStatus := "rejected"
+		if res.Line == 7 {
+			wantStatus = "created"
+		}
+		if res.Line != i+2 || res.Status != wantStatus {
+			t.Errorf("row %d = %+v, want line %d %s", i, res, i+2, wantStatus)
+		}
+	}
+	if errs := report.Rows[6].Errors; len(errs) != 1 || errs[0] != "duplicate of line 7" {
+		t.Errorf("errors of the duplicate = %q, want duplicate of line 7", errs)
+	}
+
+	offers, err := s.stores.Products.OffersByBarcode("4000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offers) != 1 || offers[0].Price != 1.2 {
+		t.Fatalf("offers after import = %+v, want the row of line 7", offers)
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// SearchProducts ranks products by how well their name matches q, combining
+// full-text rank with trigram similarity, and pages through the results the
+// same way GetProducts does.
+func (h *Handler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	page, limit, window := parsePagination(r)
+
+	results, total, err := h.products.SearchProducts(q, window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"query":    q,
+		"products": results,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/models"
+	"time"
+)
+
+// maxNameLength matches the users.name column.
+const maxNameLength = 100
+
+// updateProfileRequest leaves a field unchanged when it is omitted.
+// Changing the email address requires the current password.
+type updateProfileRequest struct {
+	Name            *string `json:"name"`
+	Email           *string `json:"email"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// checkCurrentPassword confirms a sensitive change with the account's
+// password. Wrong passwords count as failed logins, so a stolen access
+// token cannot be used to guess the password either.
+func (h *Handler) checkCurrentPassword(w http.ResponseWriter, r *http.Request, user *models.User, password string) bool {
+	wait, err := h.loginWait(time.Now().UTC(), accountLoginKey(user.Email), ipLoginKey(r))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check login attempts"}`, http.StatusInternalServerError)
+		return false
+	}
+	if wait > 0 {
+		writeLoginThrottled(w, wait)
+		return false
+	}
+
+	withPassword, err := h.users.GetUserByEmail(user.Email)
+	if err != nil || !auth.CheckPasswordHash(password, withPassword.Password) {
+		h.recordLoginFailure(r, user.Email)
+		http.Error(w, `{"error": "Current password is incorrect"}`, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// UpdateProfile changes the current user's name and email address. A new
+// address must be verified again; the old one is told about the change.
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req updateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	name, email := user.Name, user.Email
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > maxNameLength {
+			http.Error(w, fmt.Sprintf(`{"error": "Name must be 1 to %d characters"}`, maxNameLength), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Email != nil {
+		email = strings.TrimSpace(*req.Email)
+		if !strings.Contains(email, "@") || len(email) > maxNameLength {
+			http.Error(w, `{"error": "Invalid email address"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	emailChanged := email != user.Email
+
+	if emailChanged {
+		if !h.checkCurrentPassword(w, r, user, req.CurrentPassword) {
+			return
+		}
+		if existing, _ := h.users.GetUserByEmail(email); existing != nil {
+			http.Error(w, `{"error": "User with this email already exists"}`, http.StatusConflict)
+			return
+		}
+	}
+
+	if err := h.users.UpdateProfile(user.ID, name, email); err != nil {
+		http.Error(w, `{"error": "Failed to update profile"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if emailChanged {
+		log.Printf("user %d changed their email address", user.ID)
+		oldEmail := user.Email
+		user.Name, user.Email, user.EmailVerified = name, email, false
+		if err := h.sendVerificationEmail(user); err != nil {
+			log.Printf("verification email to user %d failed: %v", user.ID, err)
+		}
+		if err := h.sendEmailChangedNotice(user, oldEmail); err != nil {
+			log.Printf("email change notice to user %d failed: %v", user.ID, err)
+		}
+	}
+
+	h.writeCurrentUser(w, user.ID)
+}
+
+// sendEmailChangedNotice tells the previous address of user about the
+// change, so the owner notices if someone else made it.
+func (h *Handler) sendEmailChangedNotice(user *models.User, oldEmail string) error {
+	body := fmt.Sprintf(`Hello %s,
+
+the email address of your Supermarket Catalogue account has been changed
+to %s.
+
+If you did not make this change, reset your password and contact us.
+`, user.Name, user.Email)
+	return h.mailer.Send(oldEmail, "Your email address was changed", body)
+}
+
+// writeCurrentUser responds with the stored account.
+func (h *Handler) writeCurrentUser(w http.ResponseWriter, id int) {
+	user, err := h.users.GetUserByID(id)
+	if err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// ChangePassword sets a new password for the current user after checking
+// the current one. Every session is signed out; the caller gets a new one.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.NewPassword == "" {
+		http.Error(w, `{"error": "current_password and new_password are required"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.checkCurrentPassword(w, r, user, req.CurrentPassword) {
+		return
+	}
+	if problem := checkPassword(req.NewPassword, user); problem != "" {
+		writePasswordProblem(w, problem)
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		http.Error(w, `{"error": "New password must differ from the current one"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.users.UpdatePassword(user.ID, req.NewPassword); err != nil {
+		http.Error(w, `{"error": "Failed to update password"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(user.ID); err != nil {
+		http.Error(w, `{"error": "Failed to revoke sessions"}`, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("user %d changed their password", user.ID)
+
+	response, err := h.issueTokens(user, "", r.Header.Get("X-MFA") == "true")
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteAccount erases the current user's account after checking the
+// password; see EraseUser. The last admin cannot delete themselves, which
+// would leave nobody to manage the catalogue.
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req deleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "password is required"}`, http.StatusBadRequest)
+		return
+	}
+	if !h.checkCurrentPassword(w, r, user, req.Password) {
+		return
+	}
+
+	if user.Role == models.RoleAdmin {
+		users, err := h.users.ListUsers()
+		if err != nil {
+			http.Error(w, `{"error": "Failed to delete account"}`, http.StatusInternalServerError)
+			return
+		}
+		admins := 0
+		for _, u := range users {
+			if u.Role == models.RoleAdmin && !u.Disabled {
+				admins++
+			}
+		}
+		if admins <= 1 {
+			http.Error(w, `{"error": "The last admin cannot delete their account"}`, http.StatusConflict)
+			return
+		}
+	}
+
+	if err := h.eraseUser(user.ID); err != nil {
+		http.Error(w, `{"error": "Failed to delete account"}`, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("user %d erased their account", user.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"supermarket-catalogue/internal/middleware"
+	"supermarket-catalogue/internal/policy"
+
+	"github.com/gorilla/mux"
+)
+
+// NewRouter registers every API route on a new router. Static files and the
+// Swagger UI are left to the caller.
+func NewRouter(h *Handler, authn *middleware.Authenticator) *mux.Router {
+	r := mux.NewRouter()
+
+	r.Use(middleware.CORSMiddleware)
+	r.Use(middleware.LoggingMiddleware)
+
+	r.HandleFunc("/register", h.RegisterHandler).Methods("POST")
+	r.HandleFunc("/login", h.LoginHandler).Methods("POST")
+	r.HandleFunc("/login/2fa", h.LoginTwoFactorHandler).Methods("POST")
+	r.HandleFunc("/login/oidc", h.OIDCLoginHandler).Methods("GET")
+	r.HandleFunc("/login/oidc/callback", h.OIDCCallbackHandler).Methods("GET")
+	r.HandleFunc("/login/oidc/token", h.OIDCTokenHandler).Methods("POST")
+	r.HandleFunc("/token/refresh", h.RefreshTokenHandler).Methods("POST")
+	r.HandleFunc("/password/forgot", h.ForgotPasswordHandler).Methods("POST")
+	r.HandleFunc("/password/reset", h.ResetPasswordHandler).Methods("POST")
+	r.HandleFunc("/email/verify", h.VerifyEmailHandler).Methods("POST")
+	r.HandleFunc("/health", HealthCheck).Methods("GET")
+	r.HandleFunc("/products/compare/{barcode}", h.CompareByBarcode).Methods("GET")
+	r.HandleFunc("/products/compare/{barcode}/history", h.CompareHistoryByBarcode).Methods("GET")
+	r.HandleFunc("/products/{id}/history", h.GetProductHistory).Methods("GET")
+	r.HandleFunc("/products/search", h.SearchProducts).Methods("GET")
+	r.HandleFunc("/products/{id}", h.GetProductByID).Methods("GET")
+	r.HandleFunc("/products", h.GetProducts).Methods("GET")
+	r.HandleFunc("/items/{barcode}", h.GetCatalogItem).Methods("GET")
+	r.HandleFunc("/admin", AdminPage).Methods("GET")
+
+	authRouter := r.PathPrefix("").Subrouter()
+	authRouter.Use(authn.AuthMiddleware, middleware.UserOnlyMiddleware)
+
+	authRouter.HandleFunc("/logout", h.LogoutHandler).Methods("POST")
+	authRouter.HandleFunc("/logout-all", h.LogoutAllHandler).Methods("POST")
+	authRouter.HandleFunc("/email/resend", h.ResendVerificationHandler).Methods("POST")
+
+	authRouter.HandleFunc("/basket/compare", h.CompareBasket).Methods("POST")
+	authRouter.HandleFunc("/me", h.GetCurrentUserHandler).Methods("GET")
+	authRouter.HandleFunc("/me", h.UpdateProfile).Methods("PATCH")
+	authRouter.HandleFunc("/me", h.DeleteAccount).Methods("DELETE")
+	authRouter.HandleFunc("/me/password", h.ChangePassword).Methods("POST")
+	authRouter.HandleFunc("/me/export", h.ExportPersonalData).Methods("GET")
+	authRouter.HandleFunc("/me/permissions", h.GetPermissions).Methods("GET")
+	authRouter.HandleFunc("/me/2fa", h.GetTwoFactorStatus).Methods("GET")
+	authRouter.HandleFunc("/me/2fa/setup", h.SetupTwoFactor).Methods("POST")
+	authRouter.HandleFunc("/me/2fa/enable", h.EnableTwoFactor).Methods("POST")
+	authRouter.HandleFunc("/me/2fa/disable", h.DisableTwoFactor).Methods("POST")
+	authRouter.HandleFunc("/me/2fa/recovery-codes", h.RegenerateRecoveryCodes).Methods("POST")
+
+	authRouter.HandleFunc("/supermarkets/stats", h.GetSupermarketStats).Methods("GET")
+
+	// Accounts must confirm their email address before changing data, and
+	// privileged roles may be required to use two-factor authentication.
+	// Each route then requires a permission; see package policy.
+	protected := r.PathPrefix("").Subrouter()
+	protected.Use(authn.AuthMiddleware, middleware.VerifiedMiddleware, middleware.MFAMiddleware)
+
+	// Managers and API keys are further limited to their supermarkets by
+	// the handlers.
+	protected.Handle("/products", allow(policy.ProductCreate, h.CreateProduct)).Methods("POST")
+	protected.Handle("/products/{id}", allow(policy.ProductUpdate, h.UpdateProduct)).Methods("PUT")
+	protected.Handle("/products/{id}", allow(policy.ProductDelete, h.DeleteProduct)).Methods("DELETE")
+	protected.Handle("/users", allow(policy.UsersList, h.GetUsersHandler)).Methods("GET")
+
+	r.HandleFunc("/supermarkets", h.GetSupermarkets).Methods("GET")
+	r.HandleFunc("/supermarkets/{id}/products/export", h.ExportSupermarketProducts).Methods("GET")
+	r.HandleFunc("/supermarkets/{id}", h.GetSupermarketByID).Methods("GET")
+	r.HandleFunc("/categories", h.GetCategories).Methods("GET")
+	r.HandleFunc("/categories/tree", h.GetCategoryTree).Methods("GET")
+	r.HandleFunc("/categories/{id}/products", h.GetCategoryProducts).Methods("GET")
+	r.HandleFunc("/categories/{id}", h.GetCategoryByID).Methods("GET")
+
+	protected.Handle("/admin/supermarkets", allow(policy.SupermarketCreate, h.CreateSupermarket)).Methods("POST")
+	protected.Handle("/admin/supermarkets/{id}", allow(policy.SupermarketUpdate, h.UpdateSupermarket)).Methods("PUT")
+	protected.Handle("/admin/supermarkets/{id}", allow(policy.SupermarketDelete, h.DeleteSupermarket)).Methods("DELETE")
+	protected.Handle("/admin/supermarkets/{id}/managers", allow(policy.SupermarketManagers, h.GetSupermarketManagers)).Methods("GET")
+	protected.Handle("/admin/supermarkets/{id}/managers/{userID}", allow(policy.SupermarketManagers, h.AssignSupermarketManager)).Methods("PUT")
+	protected.Handle("/admin/supermarkets/{id}/managers/{userID}", allow(policy.SupermarketManagers, h.UnassignSupermarketManager)).Methods("DELETE")
+	protected.Handle("/admin/products/import", allow(policy.ProductImport, h.ImportProducts)).Methods("POST")
+	protected.Handle("/admin/products/export", allow(policy.ProductExport, h.ExportProducts)).Methods("GET")
+	protected.Handle("/admin/items/{barcode}", allow(policy.CatalogItemUpdate, h.UpdateCatalogItem)).Methods("PUT")
+	protected.Handle("/admin/users/{id}/role", allow(policy.UsersManage, h.UpdateUserRole)).Methods("PUT")
+	protected.Handle("/admin/users/{id}/disable", allow(policy.UsersManage, h.DisableUser)).Methods("POST")
+	protected.Handle("/admin/users/{id}/enable", allow(policy.UsersManage, h.EnableUser)).Methods("POST")
+	protected.Handle("/admin/users/{id}/unlock", allow(policy.UsersManage, h.UnlockUser)).Methods("POST")
+	protected.Handle("/admin/users/{id}/2fa/reset", allow(policy.UsersManage, h.ResetTwoFactor)).Methods("POST")
+	protected.Handle("/admin/users/{id}/erase", allow(policy.UsersManage, h.EraseUser)).Methods("POST")
+	protected.Handle("/admin/users/{id}", allow(policy.UsersManage, h.DeleteUser)).Methods("DELETE")
+	protected.Handle("/admin/ips/{ip}/unlock", allow(policy.UsersManage, h.UnlockAddress)).Methods("POST")
+	protected.Handle("/admin/api-keys", allow(policy.APIKeysManage, h.CreateAPIKey)).Methods("POST")
+	protected.Handle("/admin/api-keys", allow(policy.APIKeysManage, h.GetAPIKeys)).Methods("GET")
+	protected.Handle("/admin/api-keys/{id}", allow(policy.APIKeysManage, h.RevokeAPIKey)).Methods("DELETE")
+	protected.Handle("/admin/categories", allow(policy.CategoryCreate, h.CreateCategory)).Methods("POST")
+	protected.Handle("/admin/categories/{id}", allow(policy.CategoryUpdate, h.UpdateCategory)).Methods("PUT")
+	protected.Handle("/admin/categories/{id}", allow(policy.CategoryDelete, h.DeleteCategory)).Methods("DELETE")
+
+	return r
+}
+
+// allow wraps f in the check for permission p.
+func allow(p policy.Permission, f http.HandlerFunc) http.Handler {
+	return middleware.Require(p)(f)
+}
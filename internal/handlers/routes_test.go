@@ -0,0 +1,50 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+func TestUsersListRequiresPermission(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	s.createUser("Una", "una@example.com", "password123", models.RoleUser, true)
+	user := s.login("una@example.com", "password123")
+	admin := s.login(adminEmail, adminPassword)
+
+	expectStatus(t, s.request("GET", "/users", nil), http.StatusUnauthorized)
+	expectStatus(t, s.request("GET", "/users", nil, bearer(user.Token)...), http.StatusForbidden)
+
+	var users []models.User
+	decodeResponse(t, s.request("GET", "/users", nil, bearer(admin.Token)...), http.StatusOK, &users)
+	if len(users) != 2 {
+		t.Fatalf("listed %d users, want 2", len(users))
+	}
+}
+
+func TestPermissionsOfRole(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	u := s.createUser("Una", "una@example.com", "password123", models.RoleUser, true)
+	user := s.login("una@example.com", "password123")
+
+	var resp struct {
+		Role        string   `json:"role"`
+		Permissions []string `json:"permissions"`
+	}
+	decodeResponse(t, s.request("GET", "/me/permissions", nil, bearer(user.Token)...), http.StatusOK, &resp)
+	if resp.Role != models.RoleUser || len(resp.Permissions) != 0 {
+		t.Fatalf("permissions of user = %+v, want none", resp)
+	}
+
+	// A role change applies to tokens already issued.
+	admin := s.login(adminEmail, adminPassword)
+	path := fmt.Sprintf("/admin/users/%d/role", u.ID)
+	expectStatus(t, s.request("PUT", path, map[string]string{"role": models.RoleManager}, bearer(admin.Token)...), http.StatusOK)
+	decodeResponse(t, s.request("GET", "/me/permissions", nil, bearer(user.Token)...), http.StatusOK, &resp)
+	if resp.Role != models.RoleManager || len(resp.Permissions) == 0 {
+		t.Fatalf("permissions after promotion = %+v, want the manager's", resp)
+	}
+}
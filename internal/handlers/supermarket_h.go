@@ -1,59 +1,30 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"html/template"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"supermarket-catalogue/internal/models"
-	database "supermarket-catalogue/internal/repository"
-	"time"
+	"supermarket-catalogue/internal/repository"
 
 	"github.com/gorilla/mux"
 )
 
-func GetSupermarkets(w http.ResponseWriter, r *http.Request) {
-	rows, err := database.DB.Query(`
-		SELECT id, name, address, owner_id, created_at
-		FROM supermarkets
-		ORDER BY id
-	`)
+func (h *Handler) GetSupermarkets(w http.ResponseWriter, r *http.Request) {
+	items, err := h.supermarkets.ListSupermarkets()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	var items []models.Supermarket
-	for rows.Next() {
-		var s models.Supermarket
-		var addr sql.NullString
-		var ownerID sql.NullInt64
-		var createdAt sql.NullTime
-
-		if err := rows.Scan(&s.ID, &s.Name, &addr, &ownerID, &createdAt); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		s.Address = addr.String
-		if ownerID.Valid {
-			s.OwnerID = int(ownerID.Int64)
-		}
-		if createdAt.Valid {
-			s.CreatedAt = createdAt.Time
-		}
-
-		items = append(items, s)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(items)
 }
 
-func GetSupermarketByID(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetSupermarketByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	id, err := strconv.Atoi(idStr)
@@ -62,19 +33,9 @@ func GetSupermarketByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		SELECT id, name, address, owner_id, created_at
-		FROM supermarkets
-		WHERE id = $1
-	`
-	var s models.Supermarket
-	var addr sql.NullString
-	var ownerID sql.NullInt64
-	var createdAt sql.NullTime
-
-	err = database.DB.QueryRow(query, id).Scan(&s.ID, &s.Name, &addr, &ownerID, &createdAt)
+	s, err := h.supermarkets.GetSupermarket(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
 			return
 		}
@@ -82,44 +43,28 @@ func GetSupermarketByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.Address = addr.String
-	if ownerID.Valid {
-		s.OwnerID = int(ownerID.Int64)
-	}
-	if createdAt.Valid {
-		s.CreatedAt = createdAt.Time
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(s)
 }
 
-func CreateSupermarket(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateSupermarket(w http.ResponseWriter, r *http.Request) {
 	var s models.Supermarket
 	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
 		http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
 		return
 	}
 
-	query := `
-		INSERT INTO supermarkets (name, address, owner_id)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at
-	`
-	var createdAt time.Time
-	err := database.DB.QueryRow(query, s.Name, s.Address, s.OwnerID).Scan(&s.ID, &createdAt)
-	if err != nil {
+	if err := h.supermarkets.CreateSupermarket(&s); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	s.CreatedAt = createdAt
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(s)
 }
 
-func UpdateSupermarket(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateSupermarket(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	id, err := strconv.Atoi(idStr)
@@ -134,16 +79,10 @@ func UpdateSupermarket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		UPDATE supermarkets
-		SET name = $1, address = $2, owner_id = $3
-		WHERE id = $4
-		RETURNING created_at
-	`
-	var createdAt time.Time
-	err = database.DB.QueryRow(query, s.Name, s.Address, s.OwnerID, id).Scan(&createdAt)
+	s.ID = id
+	err = h.supermarkets.UpdateSupermarket(&s)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNotFound) {
 			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
 			return
 		}
@@ -151,14 +90,11 @@ func UpdateSupermarket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.ID = id
-	s.CreatedAt = createdAt
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(s)
 }
 
-func DeleteSupermarket(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteSupermarket(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	id, err := strconv.Atoi(idStr)
@@ -167,16 +103,15 @@ func DeleteSupermarket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec(`DELETE FROM supermarkets WHERE id = $1`, id)
+	err = h.supermarkets.DeleteSupermarket(id)
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
-		return
-	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
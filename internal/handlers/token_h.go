@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+	"time"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// issueTokens returns an access token and a new refresh token for user. An
+// empty sessionID starts a new session, that is a new refresh token family.
+// mfa records that the session was opened with a second factor.
+func (h *Handler) issueTokens(user *models.User, sessionID string, mfa bool) (*models.AuthResponse, error) {
+	if sessionID == "" {
+		var err error
+		if sessionID, err = auth.RandomToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	refresh, err := auth.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	err = h.tokens.CreateRefreshToken(&models.RefreshToken{
+		TokenHash: auth.HashToken(refresh),
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		MFA:       mfa,
+		ExpiresAt: time.Now().Add(auth.RefreshTTL()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := auth.GenerateToken(user, sessionID, mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		User: &models.User{
+			ID:    user.ID,
+			Name:  user.Name,
+			Email: user.Email,
+			Role:  user.Role,
+
+			EmailVerified: user.EmailVerified,
+			TOTPEnabled:   user.TOTPEnabled,
+		},
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int(auth.TokenTTL().Seconds()),
+	}, nil
+}
+
+// RefreshTokenHandler exchanges a refresh token for a new access token and
+// a new refresh token. Each refresh token works once; presenting one again
+// ends the whole session.
+func (h *Handler) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, `{"error": "refresh_token is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	old, err := h.tokens.ConsumeRefreshToken(auth.HashToken(req.RefreshToken))
+	if errors.Is(err, repository.ErrTokenReused) {
+		log.Printf("refresh token reuse detected; session revoked")
+		http.Error(w, `{"error": "Invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, `{"error": "Invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to refresh token"}`, http.StatusInternalServerError)
+		return
+	}
+	if old.RevokedAt != nil || time.Now().After(old.ExpiresAt) {
+		http.Error(w, `{"error": "Invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.users.GetUserByID(old.UserID)
+	if err != nil || user.Disabled {
+		http.Error(w, `{"error": "Invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	response, err := h.issueTokens(user, old.FamilyID, old.MFA)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// LogoutHandler revokes the presented access token and the session it
+// belongs to, including its refresh token.
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := strconv.Atoi(r.Header.Get("X-User-ID"))
+	expires, _ := strconv.ParseInt(r.Header.Get("X-Token-Expires"), 10, 64)
+
+	if jti := r.Header.Get("X-Token-ID"); jti != "" {
+		if err := h.tokens.RevokeAccessToken(jti, userID, time.Unix(expires, 0)); err != nil {
+			http.Error(w, `{"error": "Failed to log out"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	if sid := r.Header.Get("X-Session-ID"); sid != "" {
+		if err := h.tokens.RevokeFamily(sid); err != nil {
+			http.Error(w, `{"error": "Failed to log out"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAllHandler ends every session of the current user.
+func (h *Handler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.Header.Get("X-User-ID"))
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(userID); err != nil {
+		http.Error(w, `{"error": "Failed to log out"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
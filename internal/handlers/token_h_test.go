@@ -0,0 +1,47 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+func TestRefreshTokenRotates(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	session := s.login(adminEmail, adminPassword)
+
+	rec := s.request("POST", "/token/refresh", map[string]string{"refresh_token": session.RefreshToken})
+	var renewed models.AuthResponse
+	decodeResponse(t, rec, http.StatusOK, &renewed)
+	if renewed.RefreshToken == "" || renewed.RefreshToken == session.RefreshToken {
+		t.Fatalf("refresh returned refresh token %q, want a new one", renewed.RefreshToken)
+	}
+	expectStatus(t, s.request("GET", "/me", nil, bearer(renewed.Token)...), http.StatusOK)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	session := s.login(adminEmail, adminPassword)
+	other := s.login(adminEmail, adminPassword)
+
+	rec := s.request("POST", "/token/refresh", map[string]string{"refresh_token": session.RefreshToken})
+	var renewed models.AuthResponse
+	decodeResponse(t, rec, http.StatusOK, &renewed)
+
+	// Presenting the used token again, as a thief replaying it would,
+	// ends the session: the replay, the token issued in its place and its
+	// access tokens are all refused.
+	rec = s.request("POST", "/token/refresh", map[string]string{"refresh_token": session.RefreshToken})
+	expectStatus(t, rec, http.StatusUnauthorized)
+	rec = s.request("POST", "/token/refresh", map[string]string{"refresh_token": renewed.RefreshToken})
+	expectStatus(t, rec, http.StatusUnauthorized)
+	expectStatus(t, s.request("GET", "/me", nil, bearer(renewed.Token)...), http.StatusUnauthorized)
+	expectStatus(t, s.request("GET", "/me", nil, bearer(session.Token)...), http.StatusUnauthorized)
+
+	// Other sessions of the account are not affected.
+	expectStatus(t, s.request("GET", "/me", nil, bearer(other.Token)...), http.StatusOK)
+	rec = s.request("POST", "/token/refresh", map[string]string{"refresh_token": other.RefreshToken})
+	expectStatus(t, rec, http.StatusOK)
+}
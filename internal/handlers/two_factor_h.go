@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+	"time"
+)
+
+// totpIssuer labels the account in authenticator apps.
+const totpIssuer = "Supermarket Catalogue"
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type loginTwoFactorRequest struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
+}
+
+type disableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type twoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type twoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// twoFactorEnabledResponse hands out the recovery codes, which are never
+// shown again, and a session that has passed two-factor authentication.
+type twoFactorEnabledResponse struct {
+	*models.AuthResponse
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// writeLoginChallenge answers a correct password for an account with 2FA
+// enabled. The challenge is bound to the password, so changing it voids
+// outstanding challenges.
+func (h *Handler) writeLoginChallenge(w http.ResponseWriter, user *models.User) {
+	challenge, err := auth.GenerateActionToken(auth.PurposeLoginMFA, user.ID, user.Email,
+		auth.PasswordFingerprint(user.Password), auth.LoginMFATTL)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.LoginChallenge{
+		MFARequired: true,
+		Challenge:   challenge,
+		ExpiresIn:   int(auth.LoginMFATTL.Seconds()),
+	})
+}
+
+// checkSecondFactor accepts a current TOTP code that has not been used
+// before or, when allowRecovery is set, an unused recovery code.
+func (h *Handler) checkSecondFactor(userID int, totp *models.TOTP, code string, allowRecovery bool) (bool, error) {
+	code = strings.TrimSpace(code)
+	if step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now()); ok {
+		return h.twoFactor.UseTOTPStep(userID, step)
+	}
+	if !allowRecovery || code == "" {
+		return false, nil
+	}
+	used, err := h.twoFactor.ConsumeRecoveryCode(userID, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	if used {
+		log.Printf("user %d used a recovery code", userID)
+	}
+	return used, err
+}
+
+// checkAccountCode confirms a change to the current user's two-factor
+// settings with a code. Like checkCurrentPassword, it counts wrong codes as
+// failed logins.
+func (h *Handler) checkAccountCode(w http.ResponseWriter, r *http.Request, user *models.User, totp *models.TOTP, code string, allowRecovery bool) bool {
+	wait, err := h.loginWait(time.Now().UTC(), accountLoginKey(user.Email), ipLoginKey(r))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check login attempts"}`, http.StatusInternalServerError)
+		return false
+	}
+	if wait > 0 {
+		writeLoginThrottled(w, wait)
+		return false
+	}
+
+	ok, err := h.checkSecondFactor(user.ID, totp, code, allowRecovery)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check code"}`, http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		h.recordLoginFailure(r, user.Email)
+		http.Error(w, `{"error": "Invalid code"}`, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// newRecoveryCodes returns fresh recovery codes and their stored digests.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes, err = auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// LoginTwoFactorHandler completes a login with the challenge from
+// LoginHandler and a TOTP or recovery code. Wrong codes count as failed
+// logins.
+func (h *Handler) LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var req loginTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Challenge == "" {
+		http.Error(w, `{"error": "challenge and code are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims, err := auth.VerifyActionToken(req.Challenge, auth.PurposeLoginMFA)
+	if err != nil {
+		http.Error(w, `{"error": "Invalid or expired challenge"}`, http.StatusUnauthorized)
+		return
+	}
+
+	wait, err := h.loginWait(time.Now().UTC(), accountLoginKey(claims.Email), ipLoginKey(r))
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check login attempts"}`, http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeLoginThrottled(w, wait)
+		return
+	}
+
+	user, err := h.users.GetUserByEmail(claims.Email)
+	if err != nil || user.ID != claims.UserID || auth.PasswordFingerprint(user.Password) != claims.Fingerprint {
+		http.Error(w, `{"error": "Invalid or expired challenge"}`, http.StatusUnauthorized)
+		return
+	}
+	if user.Disabled {
+		http.Error(w, `{"error": "Account is disabled"}`, http.StatusForbidden)
+		return
+	}
+	totp, err := h.twoFactor.GetTOTP(user.ID)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && !totp.Enabled {
+		http.Error(w, `{"error": "Invalid or expired challenge"}`, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check code"}`, http.StatusInternalServerError)
+		return
+	}
+
+	ok, err := h.checkSecondFactor(user.ID, totp, req.Code, true)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check code"}`, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.recordLoginFailure(r, user.Email)
+		http.Error(w, `{"error": "Invalid code"}`, http.StatusUnauthorized)
+		return
+	}
+	if !h.consumeActionToken(w, claims) {
+		return
+	}
+	if err := h.logins.ClearLoginAttempts(accountLoginKey(user.Email)); err != nil {
+		log.Printf("clearing failed logins for user %d: %v", user.ID, err)
+	}
+
+	response, err := h.issueTokens(user, "", true)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetTwoFactorStatus reports whether the current user has 2FA enabled and
+// whether their role requires it.
+func (h *Handler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	status := twoFactorStatus{Required: auth.MFARequired(user.Role)}
+	totp, err := h.twoFactor.GetTOTP(user.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, `{"error": "Failed to load two-factor status"}`, http.StatusInternalServerError)
+		return
+	}
+	if err == nil && totp.Enabled {
+		status.Enabled = true
+		status.RecoveryCodesLeft = totp.RecoveryCodesLeft
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// SetupTwoFactor starts enrolment with a new secret. It has no effect on
+// logins until EnableTwoFactor confirms it.
+func (h *Handler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		http.Error(w, `{"error": "Two-factor authentication is already enabled"}`, http.StatusConflict)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate secret"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := h.twoFactor.SetPendingTOTP(user.ID, secret); err != nil {
+		http.Error(w, `{"error": "Failed to store secret"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(twoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor confirms the pending secret with a code from the app. It
+// ends every existing session, none of which passed a second factor, and
+// returns a new one along with the recovery codes.
+func (h *Handler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	totp, err := h.twoFactor.GetTOTP(user.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, `{"error": "Start two-factor setup first"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Failed to check code"}`, http.StatusInternalServerError)
+		return
+	}
+	if totp.Enabled {
+		http.Error(w, `{"error": "Two-factor authentication is already enabled"}`, http.StatusConflict)
+		return
+	}
+	if !h.checkAccountCode(w, r, user, totp, req.Code, false) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate recovery codes"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := h.twoFactor.EnableTOTP(user.ID, hashes); err != nil {
+		http.Error(w, `{"error": "Failed to enable two-factor authentication"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(user.ID); err != nil {
+		log.Printf("revoking sessions of user %d after enabling 2FA failed: %v", user.ID, err)
+	}
+	log.Printf("user %d enabled two-factor authentication", user.ID)
+
+	user.TOTPEnabled = true
+	response, err := h.issueTokens(user, "", true)
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(twoFactorEnabledResponse{AuthResponse: response, RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns 2FA off after checking the password and a code.
+// Accounts whose role requires 2FA cannot turn it off.
+func (h *Handler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req disableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+	if auth.MFARequired(user.Role) {
+		http.Error(w, `{"error": "Two-factor authentication is required for your role"}`, http.StatusForbidden)
+		return
+	}
+
+	totp, err := h.twoFactor.GetTOTP(user.ID)
+	if err != nil || !totp.Enabled {
+		http.Error(w, `{"error": "Two-factor authentication is not enabled"}`, http.StatusConflict)
+		return
+	}
+	if !h.checkCurrentPassword(w, r, user, req.Password) ||
+		!h.checkAccountCode(w, r, user, totp, req.Code, true) {
+		return
+	}
+
+	if err := h.twoFactor.DisableTOTP(user.ID); err != nil {
+		http.Error(w, `{"error": "Failed to disable two-factor authentication"}`, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("user %d disabled two-factor authentication", user.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the current user,
+// used or not, after checking a TOTP code.
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var req twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	totp, err := h.twoFactor.GetTOTP(user.ID)
+	if err != nil || !totp.Enabled {
+		http.Error(w, `{"error": "Two-factor authentication is not enabled"}`, http.StatusConflict)
+		return
+	}
+	if !h.checkAccountCode(w, r, user, totp, req.Code, false) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, `{"error": "Failed to generate recovery codes"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := h.twoFactor.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		http.Error(w, `{"error": "Failed to store recovery codes"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// ResetTwoFactor removes 2FA from an account that lost its device and ends
+// its sessions. The user can then log in with the password and enrol again.
+func (h *Handler) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id, ok := targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.twoFactor.DisableTOTP(id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	if err := h.tokens.RevokeUserTokens(id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("two-factor authentication of user %d reset by user %s", id, r.Header.Get("X-User-ID"))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,172 @@
+package handlers_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"supermarket-catalogue/internal/handlers"
+	"supermarket-catalogue/internal/models"
+)
+
+// enrolledUser is an account that has turned on two-factor authentication.
+type enrolledUser struct {
+	email, password string
+	secret          string
+	recoveryCodes   []string
+	// usedCode was accepted when enabling 2FA, which returned token.
+	usedCode string
+	token    string
+}
+
+func enrollTwoFactor(t *testing.T, s *testServer) *enrolledUser {
+	t.Helper()
+	u := &enrolledUser{email: "two@example.com", password: "correct horse"}
+	s.createUser("Two", u.email, u.password, models.RoleUser, true)
+	session := s.login(u.email, u.password)
+
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	decodeResponse(t, s.request("POST", "/me/2fa/setup", nil, bearer(session.Token)...), http.StatusOK, &setup)
+	u.secret = setup.Secret
+
+	u.usedCode = totpCode(t, u.secret, time.Now())
+	var enabled struct {
+		Token         string   `json:"token"`
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	rec := s.request("POST", "/me/2fa/enable", map[string]string{"code": u.usedCode}, bearer(session.Token)...)
+	decodeResponse(t, rec, http.StatusOK, &enabled)
+	if len(enabled.RecoveryCodes) == 0 {
+		t.Fatal("enabling 2FA returned no recovery codes")
+	}
+	u.recoveryCodes = enabled.RecoveryCodes
+	u.token = enabled.Token
+	return u
+}
+
+// challenge logs in with the password and returns the 2FA challenge.
+func (u *enrolledUser) challenge(t *testing.T, s *testServer) string {
+	t.Helper()
+	var c models.LoginChallenge
+	decodeResponse(t, s.request("POST", "/login", models.AuthRequest{Email: u.email, Password: u.password}), http.StatusOK, &c)
+	if !c.MFARequired || c.Challenge == "" {
+		t.Fatalf("login with 2FA enabled returned %+v, want a challenge", c)
+	}
+	return c.Challenge
+}
+
+func loginTwoFactor(s *testServer, challenge, code string) *httptest.ResponseRecorder {
+	return s.request("POST", "/login/2fa", map[string]string{"challenge": challenge, "code": code})
+}
+
+func TestTwoFactorCodeCannotBeReplayed(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	u := enrollTwoFactor(t, s)
+
+	// The code that enabled 2FA is spent, even though it is still current.
+	expectStatus(t, loginTwoFactor(s, u.challenge(t, s), u.usedCode), http.StatusUnauthorized)
+
+	// The next step's code is accepted once, within the allowed clock skew.
+	next := totpCode(t, u.secret, time.Now().Add(30*time.Second))
+	var session models.AuthResponse
+	decodeResponse(t, loginTwoFactor(s, u.challenge(t, s), next), http.StatusOK, &session)
+	expectStatus(t, s.request("GET", "/me", nil, bearer(session.Token)...), http.StatusOK)
+	expectStatus(t, loginTwoFactor(s, u.challenge(t, s), next), http.StatusUnauthorized)
+}
+
+func TestTwoFactorChallengeIsSingleUse(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	u := enrollTwoFactor(t, s)
+
+	challenge := u.challenge(t, s)
+	expectStatus(t, loginTwoFactor(s, challenge, u.recoveryCodes[0]), http.StatusOK)
+	expectStatus(t, loginTwoFactor(s, challenge, u.recoveryCodes[1]), http.StatusBadRequest)
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	u := enrollTwoFactor(t, s)
+
+	// Codes are accepted however the user types them.
+	typed := "  " + u.recoveryCodes[0] + " "
+	var session models.AuthResponse
+	decodeResponse(t, loginTwoFactor(s, u.challenge(t, s), typed), http.StatusOK, &session)
+	expectStatus(t, loginTwoFactor(s, u.challenge(t, s), u.recoveryCodes[0]), http.StatusUnauthorized)
+
+	var status struct {
+		RecoveryCodesLeft int `json:"recovery_codes_left"`
+	}
+	decodeResponse(t, s.request("GET", "/me/2fa", nil, bearer(session.Token)...), http.StatusOK, &status)
+	if want := len(u.recoveryCodes) - 1; status.RecoveryCodesLeft != want {
+		t.Errorf("recovery_codes_left = %d, want %d", status.RecoveryCodesLeft, want)
+	}
+
+	expectStatus(t, loginTwoFactor(s, u.challenge(t, s), u.recoveryCodes[1]), http.StatusOK)
+}
+
+func TestDisableTwoFactorIsThrottled(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	u := enrollTwoFactor(t, s)
+
+	// Wrong passwords count as failed logins, up to the lockout.
+	wrong := map[string]string{"password": "wrong horse", "code": u.recoveryCodes[0]}
+	for i := 0; i < testLogin.MaxFailures; i++ {
+		expectStatus(t, s.request("POST", "/me/2fa/disable", wrong, bearer(u.token)...), http.StatusForbidden)
+	}
+	right := map[string]string{"password": u.password, "code": u.recoveryCodes[0]}
+	expectStatus(t, s.request("POST", "/me/2fa/disable", right, bearer(u.token)...), http.StatusTooManyRequests)
+	expectStatus(t, s.request("POST", "/login", models.AuthRequest{Email: u.email, Password: u.password}), http.StatusTooManyRequests)
+}
+
+func TestDisableTwoFactorWithoutItRevealsNothing(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	s.createUser("One", "one@example.com", "correct horse", models.RoleUser, true)
+	session := s.login("one@example.com", "correct horse")
+
+	for _, password := range []string{"wrong horse", "correct horse"} {
+		body := map[string]string{"password": password, "code": "000000"}
+		expectStatus(t, s.request("POST", "/me/2fa/disable", body, bearer(session.Token)...), http.StatusConflict)
+	}
+}
+
+func TestWrongAccountCodesAreThrottled(t *testing.T) {
+	s := newTestServer(t, handlers.Options{})
+	u := enrollTwoFactor(t, s)
+
+	wrong := map[string]string{"code": "000000"}
+	if wrong["code"] == totpCode(t, u.secret, time.Now()) {
+		wrong["code"] = "000001"
+	}
+	for i := 0; i < testLogin.MaxFailures; i++ {
+		expectStatus(t, s.request("POST", "/me/2fa/recovery-codes", wrong, bearer(u.token)...), http.StatusBadRequest)
+	}
+	next := map[string]string{"code": totpCode(t, u.secret, time.Now().Add(30*time.Second))}
+	expectStatus(t, s.request("POST", "/me/2fa/recovery-codes", next, bearer(u.token)...), http.StatusTooManyRequests)
+}
+
+// totpCode computes the RFC 6238 code of secret for the step containing t,
+// as an authenticator app would.
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
@@ -2,14 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"supermarket-catalogue/internal/auth"
 	"supermarket-catalogue/internal/models"
-	"supermarket-catalogue/internal/repository"
+	"supermarket-catalogue/internal/policy"
+	"time"
 )
 
-func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
@@ -21,81 +23,88 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if user.Role == "" {
-		user.Role = "user"
+	if problem := checkPassword(user.Password, &user); problem != "" {
+		writePasswordProblem(w, problem)
+		return
 	}
 
-	existingUser, _ := repository.GetUserByEmail(user.Email)
+	// Roles are granted by admins, never chosen at sign-up.
+	user.Role = models.RoleUser
+	user.EmailVerified = false
+	user.Disabled = false
+
+	existingUser, _ := h.users.GetUserByEmail(user.Email)
 	if existingUser != nil {
 		http.Error(w, `{"error": "User with this email already exists"}`, http.StatusConflict)
 		return
 	}
 
-	if err := repository.CreateUser(&user); err != nil {
+	if err := h.users.CreateUser(&user); err != nil {
 		http.Error(w, `{"error": "Failed to create user"}`, http.StatusInternalServerError)
 		return
 	}
+	if err := h.sendVerificationEmail(&user); err != nil {
+		log.Printf("verification email to user %d failed: %v", user.ID, err)
+	}
 
-	token, err := auth.GenerateToken(user.ID, user.Email, user.Role)
+	response, err := h.issueTokens(&user, "", false)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
 		return
 	}
 
-	response := models.AuthResponse{
-		User: &models.User{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
-			Role:  user.Role,
-		},
-		Token: token,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var req models.AuthRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, `{"error": "Invalid request body"}`, http.StatusBadRequest)
 		return
 	}
 
-	user, err := repository.GetUserByEmail(req.Email)
+	wait, err := h.loginWait(time.Now().UTC(), accountLoginKey(req.Email), ipLoginKey(r))
 	if err != nil {
-		http.Error(w, `{"error": "Invalid email or password"}`, http.StatusUnauthorized)
+		http.Error(w, `{"error": "Failed to check login attempts"}`, http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		writeLoginThrottled(w, wait)
 		return
 	}
 
-	if !auth.CheckPasswordHash(req.Password, user.Password) {
+	user, err := h.users.GetUserByEmail(req.Email)
+	if err != nil || !auth.CheckPasswordHash(req.Password, user.Password) {
+		h.recordLoginFailure(r, req.Email)
 		http.Error(w, `{"error": "Invalid email or password"}`, http.StatusUnauthorized)
 		return
 	}
+	if user.Disabled {
+		http.Error(w, `{"error": "Account is disabled"}`, http.StatusForbidden)
+		return
+	}
+	// Failed logins are only forgiven once the second factor passes too.
+	if user.TOTPEnabled {
+		h.writeLoginChallenge(w, user)
+		return
+	}
+	if err := h.logins.ClearLoginAttempts(accountLoginKey(req.Email)); err != nil {
+		log.Printf("clearing failed logins for user %d: %v", user.ID, err)
+	}
 
-	token, err := auth.GenerateToken(user.ID, user.Email, user.Role)
+	response, err := h.issueTokens(user, "", false)
 	if err != nil {
 		http.Error(w, `{"error": "Failed to generate token"}`, http.StatusInternalServerError)
 		return
 	}
 
-	response := models.AuthResponse{
-		User: &models.User{
-			ID:    user.ID,
-			Name:  user.Name,
-			Email: user.Email,
-			Role:  user.Role,
-		},
-		Token: token,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := repository.GetAllUsers()
+func (h *Handler) GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := h.users.ListUsers()
 	if err != nil {
 		http.Error(w, `{"error": "Failed to fetch users"}`, http.StatusInternalServerError)
 		return
@@ -109,7 +118,7 @@ func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(users)
 }
 
-func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 	userIDStr := r.Header.Get("X-User-ID")
 	if userIDStr == "" {
 		http.Error(w, `{"error": "Not authenticated"}`, http.StatusUnauthorized)
@@ -122,7 +131,7 @@ func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := repository.GetUserByID(userID)
+	user, err := h.users.GetUserByID(userID)
 	if err != nil {
 		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
 		return
@@ -131,3 +140,34 @@ func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
+
+// currentUser loads the account behind the request's access token, writing
+// an error response when it cannot.
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := strconv.Atoi(r.Header.Get("X-User-ID"))
+	if err != nil {
+		http.Error(w, `{"error": "Invalid user ID"}`, http.StatusBadRequest)
+		return nil, false
+	}
+	user, err := h.users.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, `{"error": "User not found"}`, http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+// GetPermissions lists what the current user's role allows, for clients
+// deciding which controls to show.
+func (h *Handler) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"role":        user.Role,
+		"permissions": policy.RolePermissions(user.Role),
+	})
+}
@@ -0,0 +1,88 @@
+// Package mail delivers the account emails sent by the API, such as
+// password reset and address verification links.
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"supermarket-catalogue/internal/config"
+)
+
+// Mailer sends a plain-text message to a single recipient.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// New returns the Mailer selected by cfg.Driver.
+func New(cfg config.MailConfig) Mailer {
+	if cfg.Driver == config.MailDriverSMTP {
+		return &SMTPMailer{cfg: cfg}
+	}
+	return &LogMailer{From: cfg.From, File: cfg.File}
+}
+
+// SMTPMailer delivers through an SMTP relay, authenticating with PLAIN auth
+// when a username is configured.
+type SMTPMailer struct {
+	cfg config.MailConfig
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	return smtp.SendMail(addr, auth, m.cfg.From, []string{to}, message(m.cfg.From, to, subject, body))
+}
+
+// LogMailer is for local development: messages are appended to File, or
+// written to the server log when File is empty, instead of being sent.
+type LogMailer struct {
+	From string
+	File string
+
+	mu sync.Mutex
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	msg := message(m.From, to, subject, body)
+	if m.File == "" {
+		log.Printf("mail (not sent):\n%s", msg)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\n\n", msg)
+	return err
+}
+
+// message formats an RFC 5322 message. Header values come from trusted
+// configuration or stored addresses; line breaks are stripped regardless so
+// they cannot inject headers.
+func message(from, to, subject, body string) []byte {
+	clean := strings.NewReplacer("\r", "", "\n", "")
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", clean.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean.Replace(to))
+	fmt.Fprintf(&b, "Subject: %s\r\n", clean.Replace(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -1,14 +1,50 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/policy"
+	"supermarket-catalogue/internal/repository"
+	"time"
 )
 
-func AuthMiddleware(next http.Handler) http.Handler {
+// Authenticator checks bearer tokens against the stores that can invalidate
+// them before they expire.
+type Authenticator struct {
+	tokens  repository.TokenStore
+	users   repository.UserStore
+	apiKeys repository.APIKeyStore
+}
+
+func NewAuthenticator(stores *repository.Stores) *Authenticator {
+	return &Authenticator{tokens: stores.Tokens, users: stores.Users, apiKeys: stores.APIKeys}
+}
+
+// identityHeaders carry the authenticated caller to handlers. Values sent by
+// the client are dropped before authentication.
+var identityHeaders = []string{
+	"X-User-ID", "X-User-Role", "X-Token-ID", "X-Token-Expires", "X-Session-ID",
+	"X-Email-Verified", "X-MFA", "X-API-Key-ID", "X-API-Scopes", "X-API-Supermarket-ID",
+}
+
+// AuthMiddleware verifies the bearer token, rejects revoked token IDs and
+// sessions as well as disabled or deleted accounts, and passes the caller on
+// to handlers in X-User-ID, X-User-Role, X-Token-ID, X-Token-Expires,
+// X-Session-ID, X-Email-Verified and X-MFA. Role and verification status are read
+// from the account, so changes apply to tokens already issued.
+//
+// Requests without a bearer token may instead present an API key in
+// X-API-Key; see serveAPIKey.
+func (a *Authenticator) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range identityHeaders {
+			r.Header.Del(h)
+		}
 		path := r.URL.Path
 
 		publicRoutes := map[string]bool{
@@ -26,6 +62,10 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		authHeader := r.Header.Get("Authorization")
+		if key := r.Header.Get("X-API-Key"); key != "" && authHeader == "" {
+			a.serveAPIKey(w, r, next, key)
+			return
+		}
 		if authHeader == "" {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
@@ -48,18 +88,131 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			w.Write([]byte(`{"error": "Invalid or expired token"}`))
 			return
 		}
+
+		revoked, err := a.tokens.IsRevoked(claims.Id, claims.SessionID)
+		if err != nil {
+			http.Error(w, `{"error": "Failed to check token"}`, http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Token has been revoked"}`))
+			return
+		}
+
+		user, err := a.users.GetUserByID(claims.UserID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, `{"error": "Failed to check token"}`, http.StatusInternalServerError)
+			return
+		}
+		if user == nil || user.Disabled {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Account is disabled"}`))
+			return
+		}
+
 		r.Header.Set("X-User-ID", strconv.Itoa(claims.UserID))
-		r.Header.Set("X-User-Role", claims.Role)
+		r.Header.Set("X-User-Role", user.Role)
+		r.Header.Set("X-Token-ID", claims.Id)
+		r.Header.Set("X-Token-Expires", strconv.FormatInt(claims.ExpiresAt, 10))
+		r.Header.Set("X-Session-ID", claims.SessionID)
+		r.Header.Set("X-Email-Verified", strconv.FormatBool(user.EmailVerified))
+		r.Header.Set("X-MFA", strconv.FormatBool(claims.MFA))
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func AdminMiddleware(next http.Handler) http.Handler {
+// serveAPIKey authenticates a machine client. The key's ID, scopes and
+// supermarket are passed on in X-API-Key-ID, X-API-Scopes (comma-separated)
+// and X-API-Supermarket-ID; no user headers are set.
+func (a *Authenticator) serveAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	k, err := a.apiKeys.GetAPIKeyByHash(auth.HashToken(key))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, `{"error": "Failed to check API key"}`, http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	if k == nil || k.RevokedAt != nil || k.ExpiresAt != nil && now.After(*k.ExpiresAt) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": "Invalid API key"}`))
+		return
+	}
+	if err := a.apiKeys.TouchAPIKey(k.ID, now); err != nil {
+		log.Printf("recording use of API key %d: %v", k.ID, err)
+	}
+
+	r.Header.Set("X-API-Key-ID", strconv.Itoa(k.ID))
+	r.Header.Set("X-API-Scopes", strings.Join(k.Scopes, ","))
+	r.Header.Set("X-API-Supermarket-ID", strconv.Itoa(k.SupermarketID))
+
+	next.ServeHTTP(w, r)
+}
+
+// isAPIKey reports whether AuthMiddleware authenticated r by API key.
+func isAPIKey(r *http.Request) bool {
+	return r.Header.Get("X-API-Key-ID") != ""
+}
+
+// Can reports whether the caller of r holds permission p, through the role
+// of its account or the scopes of its API key. It is meaningful only after
+// AuthMiddleware.
+func Can(r *http.Request, p policy.Permission) bool {
+	if isAPIKey(r) {
+		return policy.ScopesAllow(strings.Split(r.Header.Get("X-API-Scopes"), ","), p)
+	}
+	return policy.RoleAllows(r.Header.Get("X-User-Role"), p)
+}
+
+// Require returns middleware that refuses callers without permission p. It
+// must run after AuthMiddleware.
+func Require(p policy.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Can(r, p) {
+				http.Error(w, fmt.Sprintf(`{"error": "Permission %s required"}`, p), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UserOnlyMiddleware refuses API keys on routes that act on the calling
+// user's own account. It must run after AuthMiddleware.
+func UserOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAPIKey(r) {
+			http.Error(w, `{"error": "Not available to API keys"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MFAMiddleware refuses sessions that did not pass two-factor authentication
+// when the caller's role requires it. API keys have no role and pass. It
+// must run after AuthMiddleware.
+func MFAMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.MFARequired(r.Header.Get("X-User-Role")) && r.Header.Get("X-MFA") != "true" {
+			http.Error(w, `{"error": "Two-factor authentication required"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// VerifiedMiddleware lets through only users who have confirmed their email
+// address, and API keys, which an admin issued. It must run after
+// AuthMiddleware.
+func VerifiedMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		role := r.Header.Get("X-User-Role")
-		if role != "admin" {
-			http.Error(w, `{"error": "Admin access required"}`, http.StatusForbidden)
+		if r.Header.Get("X-Email-Verified") != "true" && !isAPIKey(r) {
+			http.Error(w, `{"error": "Email address not verified"}`, http.StatusForbidden)
 			return
 		}
 		next.ServeHTTP(w, r)
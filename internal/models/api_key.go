@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// API key scopes. A key may only do what its scopes allow.
+const (
+	// ScopeProductsWrite allows creating, updating and deleting products,
+	// within the key's supermarket when it has one.
+	ScopeProductsWrite = "products:write"
+)
+
+// ValidScope reports whether scope is one of the known API key scopes.
+func ValidScope(scope string) bool {
+	return scope == ScopeProductsWrite
+}
+
+// APIKey lets a machine client such as a scraper or a till system call the
+// API without a user login. Only a digest of the key is stored; Prefix
+// identifies it in listings.
+type APIKey struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	// SupermarketID limits the key to one supermarket; zero means all.
+	SupermarketID int        `json:"supermarket_id,omitempty"`
+	CreatedBy     int        `json:"created_by,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	KeyHash       string     `json:"-"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -15,13 +15,18 @@ type Product struct {
 	UnitPrice     float64   `json:"unit_price,omitempty"`
 	LastUpdated   time.Time `json:"last_updated,omitempty"`
 	SupermarketID int       `json:"supermarket_id,omitempty"`
+	// ItemID links the product to the catalog item for its barcode.
+	ItemID int `json:"item_id,omitempty"`
 }
 
 type Category struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
-	OwnerID     int    `json:"owner_id,omitempty"`
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	ParentID    int        `json:"parent_id,omitempty"`
+	OwnerID     int        `json:"owner_id,omitempty"`
+	CreatedAt   time.Time  `json:"created_at,omitempty"`
+	Children    []Category `json:"children,omitempty"`
 }
 
 type Supermarket struct {
@@ -31,3 +36,79 @@ type Supermarket struct {
 	OwnerID   int       `json:"owner_id,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 }
+
+type PricePoint struct {
+	Price      float64   `json:"price"`
+	UnitPrice  float64   `json:"unit_price,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+type PriceSeries struct {
+	SupermarketID   int          `json:"supermarket_id"`
+	SupermarketName string       `json:"supermarket_name,omitempty"`
+	History         []PricePoint `json:"history"`
+}
+
+type ProductSearchResult struct {
+	Product
+	Score           float64 `json:"score"`
+	HighlightedName string  `json:"highlighted_name"`
+}
+
+// CatalogItem is the canonical description of a barcode, shared by every
+// supermarket that sells it.
+type CatalogItem struct {
+	ID         int       `json:"id"`
+	Barcode    string    `json:"barcode"`
+	Name       string    `json:"name"`
+	Brand      string    `json:"brand,omitempty"`
+	Unit       string    `json:"unit,omitempty"`
+	CategoryID int       `json:"category_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+	Offers     []Offer   `json:"offers,omitempty"`
+}
+
+// Offer is one supermarket's price and stock for a catalog item.
+type Offer struct {
+	ProductID       int       `json:"product_id"`
+	SupermarketID   int       `json:"supermarket_id,omitempty"`
+	SupermarketName string    `json:"supermarket_name,omitempty"`
+	Price           float64   `json:"price"`
+	UnitPrice       float64   `json:"unit_price,omitempty"`
+	Stock           int       `json:"stock"`
+	LastUpdated     time.Time `json:"last_updated,omitempty"`
+}
+
+// BarcodeOffer is one supermarket's listing of a barcode.
+type BarcodeOffer struct {
+	ProductID       int      `json:"product_id"`
+	Name            string   `json:"name"`
+	Price           float64  `json:"price"`
+	UnitPrice       *float64 `json:"unit_price,omitempty"`
+	Unit            string   `json:"unit,omitempty"`
+	SupermarketID   *int     `json:"supermarket_id,omitempty"`
+	SupermarketName string   `json:"supermarket_name,omitempty"`
+	LastUpdated     *string  `json:"last_updated,omitempty"`
+}
+
+// Substitute is the cheapest product a store offers in the same category and
+// unit as a basket item it does not stock.
+type Substitute struct {
+	ForBarcode string  `json:"for_barcode"`
+	ProductID  int     `json:"product_id"`
+	Name       string  `json:"name"`
+	Barcode    string  `json:"barcode,omitempty"`
+	Quantity   int     `json:"quantity"`
+	Price      float64 `json:"price"`
+	Cost       float64 `json:"cost"`
+}
+
+type SupermarketStats struct {
+	SupermarketID   int     `json:"supermarket_id"`
+	SupermarketName string  `json:"supermarket_name"`
+	ProductCount    int     `json:"product_count"`
+	AvgPrice        float64 `json:"avg_price"`
+	MinPrice        float64 `json:"min_price"`
+	MaxPrice        float64 `json:"max_price"`
+}
@@ -2,6 +2,20 @@ package models
 
 import "time"
 
+// Roles a user can hold.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+	// RoleManager maintains the products of the supermarkets it owns or
+	// is assigned to.
+	RoleManager = "manager"
+)
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin || role == RoleManager
+}
+
 type User struct {
 	ID        int       `json:"id"`
 	Name      string    `json:"name"`
@@ -9,8 +23,20 @@ type User struct {
 	Password  string    `json:"password"`
 	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
+	// EmailVerified is set once the user follows the verification link.
+	EmailVerified bool `json:"email_verified"`
+	// Disabled accounts cannot log in and their tokens are refused.
+	Disabled bool `json:"disabled"`
+	// TOTPEnabled accounts complete every login with a one-time code.
+	TOTPEnabled bool `json:"totp_enabled"`
+	// Erased accounts had their personal data removed on request. They are
+	// kept, disabled, so the records they own keep a valid owner.
+	Erased bool `json:"erased,omitempty"`
 }
 
+// ErasedUserName replaces the name of erased accounts.
+const ErasedUserName = "Deleted user"
+
 type AuthRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -19,4 +45,64 @@ type AuthRequest struct {
 type AuthResponse struct {
 	User  *User  `json:"user"`
 	Token string `json:"token"`
+	// RefreshToken is exchanged at /token/refresh for a new pair once
+	// Token, valid for ExpiresIn seconds, runs out.
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+}
+
+// LoginChallenge is returned by a password login when the account uses
+// two-factor authentication. Challenge is exchanged together with a code at
+// /login/2fa for an AuthResponse.
+type LoginChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	Challenge   string `json:"challenge"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// TOTP is the two-factor state of an account. Secret is set from setup on;
+// Enabled once the user has confirmed it with a code.
+type TOTP struct {
+	Secret  string
+	Enabled bool
+	// LastStep is the time step of the last code accepted, so a code cannot
+	// be used twice.
+	LastStep          int64
+	RecoveryCodesLeft int
+}
+
+// RefreshToken is the server-side record of an issued refresh token. Tokens
+// rotated from one login share a FamilyID, which access tokens carry as
+// their session ID.
+type RefreshToken struct {
+	ID        int
+	TokenHash string
+	UserID    int
+	FamilyID  string
+	// MFA carries over to the access tokens issued from the family.
+	MFA       bool
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// LoginAttempts tracks the consecutive failed logins for a throttle key,
+// such as an account or a client address.
+type LoginAttempts struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// Identity links a user to their account at an OpenID Connect provider,
+// named by the provider's issuer URL and the subject it assigns.
+type Identity struct {
+	UserID  int    `json:"-"`
+	Issuer  string `json:"issuer"`
+	Subject string `json:"subject"`
+	// Email is the address the provider reported when the link was made.
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -0,0 +1,393 @@
+// Package oidc is a small OpenID Connect relying party for the
+// authorization code flow with PKCE. It discovers the provider's endpoints,
+// redeems authorization codes and validates the RS256-signed ID tokens
+// against the provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"supermarket-catalogue/internal/config"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// leeway absorbs clock skew between us and the provider.
+const leeway = time.Minute
+
+// keyRefreshInterval limits how often an unknown key ID makes us fetch the
+// provider's keys again, so forged tokens cannot hammer the provider.
+const keyRefreshInterval = time.Minute
+
+// maxResponseSize caps what we read from the provider.
+const maxResponseSize = 1 << 20
+
+// Provider talks to one OpenID Connect provider. Discovery and the key set
+// are fetched on first use and cached, so the server starts while the
+// provider is down.
+type Provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	client       *http.Client
+
+	mu          sync.Mutex
+	meta        *metadata
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+// metadata is the part of the discovery document we use.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDToken holds the verified claims of an ID token that we act on.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// New returns a client for the provider in cfg. redirectURL is where the
+// provider sends the browser back with the authorization code.
+func New(cfg config.OIDCConfig, redirectURL string) *Provider {
+	return &Provider{
+		issuer:       cfg.Issuer,
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		redirectURL:  redirectURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Issuer is the provider's issuer URL, which together with the subject
+// identifies a user.
+func (p *Provider) Issuer() string {
+	return p.issuer
+}
+
+// CodeChallenge derives the S256 PKCE challenge sent for verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns the provider URL that starts a login. state and nonce
+// must be random per login; verifier is the PKCE code verifier later passed
+// to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("oidc: authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.clientID)
+	q.Set("redirect_uri", p.redirectURL)
+	q.Set("scope", "openid email profile")
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns
+// the raw ID token, which the caller must pass to Verify.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"code_verifier": {verifier},
+		"client_id":     {p.clientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.clientID), url.QueryEscape(p.clientSecret))
+	}
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &body)
+	if err != nil {
+		return "", fmt.Errorf("oidc: token request: %w", err)
+	}
+	if body.Error != "" {
+		return "", fmt.Errorf("oidc: token request: %s %s", body.Error, body.ErrorDescription)
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("oidc: token request: status %d", status)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("oidc: token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// idTokenClaims are the ID token claims we check or use.
+type idTokenClaims struct {
+	Issuer          string    `json:"iss"`
+	Subject         string    `json:"sub"`
+	Audience        audience  `json:"aud"`
+	AuthorizedParty string    `json:"azp"`
+	ExpiresAt       int64     `json:"exp"`
+	IssuedAt        int64     `json:"iat"`
+	Nonce           string    `json:"nonce"`
+	Email           string    `json:"email"`
+	EmailVerified   looseBool `json:"email_verified"`
+	Name            string    `json:"name"`
+}
+
+// Valid checks the token lifetime; Verify checks the rest.
+func (c *idTokenClaims) Valid() error {
+	now := time.Now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(leeway)) {
+		return errors.New("token is expired")
+	}
+	if c.IssuedAt != 0 && now.Add(leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.New("token used before issued")
+	}
+	return nil
+}
+
+// audience accepts both forms of the aud claim: one string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// looseBool accepts true as well as "true", which some providers send for
+// email_verified.
+type looseBool bool
+
+func (b *looseBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case `true`, `"true"`:
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// Verify checks the signature of an ID token against the provider's keys,
+// its issuer, audience and lifetime, and that it carries nonce.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (*IDToken, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("oidc: id token: %w", err)
+	}
+
+	switch {
+	case claims.Issuer != p.issuer:
+		return nil, fmt.Errorf("oidc: id token issued by %q", claims.Issuer)
+	case !claims.Audience.contains(p.clientID):
+		return nil, errors.New("oidc: id token is not for this client")
+	case claims.AuthorizedParty != "" && claims.AuthorizedParty != p.clientID:
+		return nil, errors.New("oidc: id token is authorized for another client")
+	case claims.Nonce != nonce:
+		return nil, errors.New("oidc: id token nonce mismatch")
+	case claims.Subject == "":
+		return nil, errors.New("oidc: id token has no subject")
+	}
+	return &IDToken{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover fetches the discovery document once it is first needed. Failures
+// are not cached, so a provider outage ends when the provider recovers.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(p.issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var meta metadata
+	status, err := p.do(req, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery: status %d", status)
+	}
+	if meta.Issuer != p.issuer {
+		return nil, fmt.Errorf("oidc: discovery: provider claims issuer %q", meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery: endpoints missing")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// key returns the provider's signing key kid, fetching the key set again
+// when kid is unknown, as providers rotate keys. An empty kid is accepted
+// when the provider has a single key.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set jsonWebKeySet
+	status, err := p.do(req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetching signing keys: status %d", status)
+	}
+	p.keys = set.rsaKeys()
+	p.keysFetched = time.Now()
+
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey must be called with p.mu held.
+func (p *Provider) lookupKey(kid string) *rsa.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// rsaKeys returns the RSA signing keys of the set by key ID, skipping keys
+// of other types or uses and malformed ones.
+func (s jsonWebKeySet) rsaKeys() map[string]*rsa.PublicKey {
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range s.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys
+}
+
+// do sends req and decodes a JSON response into v, whatever the status,
+// which it returns for the caller to judge.
+func (p *Provider) do(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("status %d: %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,80 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"supermarket-catalogue/internal/config"
+	"supermarket-catalogue/internal/oidc"
+	"supermarket-catalogue/internal/oidc/oidctest"
+)
+
+const verifier = "verifier-0123456789-0123456789-0123456789"
+
+// approve starts a login for nonce and returns the code the provider sends
+// back.
+func approve(t *testing.T, p *oidc.Provider, nonce string) string {
+	t.Helper()
+	target, err := p.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := browser.Get(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback.Query().Get("code")
+}
+
+func TestVerify(t *testing.T) {
+	user := oidctest.User{Subject: "sub-1", Email: "olga@example.com", EmailVerified: true}
+	srv, _, err := oidctest.NewServer("catalogue", user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	p := oidc.New(config.OIDCConfig{Issuer: srv.URL, ClientID: "catalogue"}, "http://catalogue.test/login/oidc/callback")
+
+	raw, err := p.Exchange(context.Background(), approve(t, p, "nonce-1"), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Verify(context.Background(), raw, "nonce-2"); err == nil {
+		t.Error("token verified against another nonce")
+	}
+	idToken, err := p.Verify(context.Background(), raw, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idToken.Subject != user.Subject || idToken.Email != user.Email || !idToken.EmailVerified {
+		t.Errorf("Verify = %+v, want %+v", idToken, user)
+	}
+
+	other := oidc.New(config.OIDCConfig{Issuer: srv.URL, ClientID: "other"}, "http://catalogue.test/login/oidc/callback")
+	if _, err := other.Verify(context.Background(), raw, "nonce-1"); err == nil {
+		t.Error("token verified for another client")
+	}
+}
+
+func TestExchangeChecksVerifier(t *testing.T) {
+	srv, _, err := oidctest.NewServer("catalogue", oidctest.User{Subject: "sub-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	p := oidc.New(config.OIDCConfig{Issuer: srv.URL, ClientID: "catalogue"}, "http://catalogue.test/login/oidc/callback")
+
+	code := approve(t, p, "nonce")
+	if _, err := p.Exchange(context.Background(), code, verifier+"x"); err == nil {
+		t.Fatal("code redeemed with another verifier")
+	}
+}
@@ -0,0 +1,93 @@
+// Package policy maps roles and API key scopes to the permissions that
+// routes require, so access rules live in one table rather than in role
+// comparisons scattered over handlers.
+package policy
+
+import (
+	"sort"
+
+	"supermarket-catalogue/internal/models"
+)
+
+// Permission names one action on one kind of resource.
+type Permission string
+
+const (
+	ProductCreate Permission = "product.create"
+	ProductUpdate Permission = "product.update"
+	ProductDelete Permission = "product.delete"
+	// ProductAnySupermarket lifts the restriction of product writes to the
+	// supermarkets a manager owns or is assigned to.
+	ProductAnySupermarket Permission = "product.any_supermarket"
+	ProductImport         Permission = "product.import"
+	ProductExport         Permission = "product.export"
+
+	CatalogItemUpdate Permission = "catalog_item.update"
+
+	SupermarketCreate   Permission = "supermarket.create"
+	SupermarketUpdate   Permission = "supermarket.update"
+	SupermarketDelete   Permission = "supermarket.delete"
+	SupermarketManagers Permission = "supermarket.managers"
+
+	CategoryCreate Permission = "category.create"
+	CategoryUpdate Permission = "category.update"
+	CategoryDelete Permission = "category.delete"
+
+	UsersList Permission = "users.list"
+	// UsersManage covers roles, disabling, deletion, lockouts and 2FA
+	// resets of other accounts.
+	UsersManage Permission = "users.manage"
+
+	APIKeysManage Permission = "api_keys.manage"
+)
+
+// productWrite is what store staff and price feeds may do.
+var productWrite = []Permission{ProductCreate, ProductUpdate, ProductDelete}
+
+var rolePermissions = map[string][]Permission{
+	models.RoleUser:    nil,
+	models.RoleManager: productWrite,
+	models.RoleAdmin: append(append([]Permission{}, productWrite...),
+		ProductAnySupermarket, ProductImport, ProductExport,
+		CatalogItemUpdate,
+		SupermarketCreate, SupermarketUpdate, SupermarketDelete, SupermarketManagers,
+		CategoryCreate, CategoryUpdate, CategoryDelete,
+		UsersList, UsersManage,
+		APIKeysManage,
+	),
+}
+
+var scopePermissions = map[string][]Permission{
+	models.ScopeProductsWrite: productWrite,
+}
+
+// RoleAllows reports whether role grants p. Unknown roles grant nothing.
+func RoleAllows(role string, p Permission) bool {
+	return contains(rolePermissions[role], p)
+}
+
+// ScopesAllow reports whether any of an API key's scopes grants p.
+func ScopesAllow(scopes []string, p Permission) bool {
+	for _, scope := range scopes {
+		if contains(scopePermissions[scope], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissions lists the permissions of role, sorted.
+func RolePermissions(role string) []Permission {
+	perms := append([]Permission{}, rolePermissions[role]...)
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+func contains(perms []Permission, p Permission) bool {
+	for _, have := range perms {
+		if have == p {
+			return true
+		}
+	}
+	return false
+}
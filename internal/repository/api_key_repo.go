@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"database/sql"
+	"supermarket-catalogue/internal/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, supermarket_id, created_by, expires_at, last_used_at, revoked_at, created_at`
+
+// apiKeyTouchInterval limits how often using a key writes its last-used
+// time, so a busy feed does not turn every request into a write.
+const apiKeyTouchInterval = time.Minute
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var k models.APIKey
+	var supermarketID, createdBy sql.NullInt64
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.KeyHash, pq.Array(&k.Scopes), &supermarketID, &createdBy,
+		&expiresAt, &lastUsedAt, &revokedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	k.SupermarketID = int(supermarketID.Int64)
+	k.CreatedBy = int(createdBy.Int64)
+	if expiresAt.Valid {
+		k.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	return &k, nil
+}
+
+func (pg *Postgres) CreateAPIKey(k *models.APIKey) error {
+	return pg.db.QueryRow(`
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, supermarket_id, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), nullableID(k.SupermarketID), nullableID(k.CreatedBy), k.ExpiresAt).
+		Scan(&k.ID, &k.CreatedAt)
+}
+
+func (pg *Postgres) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	k, err := scanAPIKey(pg.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return k, err
+}
+
+func (pg *Postgres) ListAPIKeys() ([]models.APIKey, error) {
+	rows, err := pg.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+func (pg *Postgres) RevokeAPIKey(id int) error {
+	return execOne(pg.db, `
+		UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL
+	`, id)
+}
+
+func (pg *Postgres) TouchAPIKey(id int, at time.Time) error {
+	_, err := pg.db.Exec(`
+		UPDATE api_keys SET last_used_at = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)
+	`, at, id, at.Add(-apiKeyTouchInterval))
+	return err
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"supermarket-catalogue/internal/models"
+)
+
+// ensureCatalogItem points p at the catalog item for its barcode, creating
+// the item from p when the barcode is new. An existing item keeps its name
+// so every offer of a barcode is listed under one name.
+func ensureCatalogItem(tx *sql.Tx, p *models.Product) error {
+	p.ItemID = 0
+	if p.Barcode == "" {
+		return nil
+	}
+	return tx.QueryRow(`
+		INSERT INTO catalog_items (barcode, name, unit, category_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (barcode) DO UPDATE SET barcode = EXCLUDED.barcode
+		RETURNING id
+	`, p.Barcode, p.Name, p.Unit, nullableID(p.CategoryID)).Scan(&p.ItemID)
+}
+
+func (pg *Postgres) GetCatalogItem(barcode string) (*models.CatalogItem, error) {
+	var item models.CatalogItem
+	var brand, unit sql.NullString
+	var categoryID sql.NullInt64
+	var createdAt, updatedAt sql.NullTime
+	err := pg.db.QueryRow(`
+		SELECT id, barcode, name, brand, unit, category_id, created_at, updated_at
+		FROM catalog_items
+		WHERE barcode = $1
+	`, barcode).Scan(&item.ID, &item.Barcode, &item.Name, &brand, &unit, &categoryID, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	item.Brand = brand.String
+	item.Unit = unit.String
+	item.CategoryID = int(categoryID.Int64)
+	item.CreatedAt = createdAt.Time
+	item.UpdatedAt = updatedAt.Time
+
+	rows, err := pg.db.Query(`
+		SELECT o.product_id, o.supermarket_id, s.name, o.price, o.unit_price, o.stock, o.last_updated
+		FROM offers o
+		LEFT JOIN supermarkets s ON o.supermarket_id = s.id
+		WHERE o.item_id = $1
+		ORDER BY COALESCE(o.unit_price, o.price), o.product_id
+	`, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	item.Offers = []models.Offer{}
+	for rows.Next() {
+		var o models.Offer
+		var supermarketID sql.NullInt64
+		var supermarketName sql.NullString
+		var unitPrice sql.NullFloat64
+		var lastUpdated sql.NullTime
+		if err := rows.Scan(&o.ProductID, &supermarketID, &supermarketName, &o.Price, &unitPrice, &o.Stock, &lastUpdated); err != nil {
+			return nil, err
+		}
+		o.SupermarketID = int(supermarketID.Int64)
+		o.SupermarketName = supermarketName.String
+		o.UnitPrice = unitPrice.Float64
+		o.LastUpdated = lastUpdated.Time
+		item.Offers = append(item.Offers, o)
+	}
+	return &item, rows.Err()
+}
+
+func (pg *Postgres) UpdateCatalogItem(item *models.CatalogItem) error {
+	return inTx(pg.db, func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+			UPDATE catalog_items
+			SET name = $1, brand = $2, unit = $3, category_id = $4, updated_at = CURRENT_TIMESTAMP
+			WHERE barcode = $5
+			RETURNING id, created_at, updated_at
+		`, item.Name, item.Brand, item.Unit, nullableID(item.CategoryID), item.Barcode).
+			Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// Listings, search and exports read these columns of the offers.
+		_, err = tx.Exec(`
+			UPDATE products
+			SET name = $1, unit = $2, category_id = $3
+			WHERE item_id = $4
+		`, item.Name, item.Unit, nullableID(item.CategoryID), item.ID)
+		return err
+	})
+}
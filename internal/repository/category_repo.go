@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"supermarket-catalogue/internal/models"
+)
+
+const categoryColumns = `id, name, description, parent_id, owner_id, created_at`
+
+func (pg *Postgres) ListCategories() ([]models.Category, error) {
+	rows, err := pg.db.Query(`
+		SELECT ` + categoryColumns + `
+		FROM categories
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *c)
+	}
+	return items, rows.Err()
+}
+
+func (pg *Postgres) GetCategory(id int) (*models.Category, error) {
+	c, err := scanCategory(pg.db.QueryRow(`SELECT `+categoryColumns+` FROM categories WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+func (pg *Postgres) CategoryExists(id int) (bool, error) {
+	var exists bool
+	err := pg.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+func (pg *Postgres) CreateCategory(c *models.Category) error {
+	err := pg.db.QueryRow(`
+		INSERT INTO categories (name, description, parent_id, owner_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, c.Name, c.Description, nullableID(c.ParentID), nullableID(c.OwnerID)).Scan(&c.ID, &c.CreatedAt)
+	return conflict(err)
+}
+
+func (pg *Postgres) UpdateCategory(c *models.Category) error {
+	var ownerID sql.NullInt64
+	err := pg.db.QueryRow(`
+		UPDATE categories
+		SET name = $1, description = $2, parent_id = $3
+		WHERE id = $4
+		RETURNING owner_id, created_at
+	`, c.Name, c.Description, nullableID(c.ParentID), c.ID).Scan(&ownerID, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	c.OwnerID = int(ownerID.Int64)
+	return conflict(err)
+}
+
+func (pg *Postgres) DeleteCategory(id int) error {
+	// Children become root categories, which may clash with an existing
+	// root of the same name.
+	result, err := pg.db.Exec(`DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return conflict(err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (pg *Postgres) CategorySubtree(id int) ([]int, error) {
+	rows, err := pg.db.Query(`
+		WITH RECURSIVE subtree AS (
+			SELECT id, 0 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, s.depth + 1 FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT id FROM subtree ORDER BY depth, id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var cid int
+		if err := rows.Scan(&cid); err != nil {
+			return nil, err
+		}
+		ids = append(ids, cid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrNotFound
+	}
+	return ids, nil
+}
+
+func scanCategory(row rowScanner) (*models.Category, error) {
+	var c models.Category
+	var desc sql.NullString
+	var parentID, ownerID sql.NullInt64
+	var createdAt sql.NullTime
+
+	if err := row.Scan(&c.ID, &c.Name, &desc, &parentID, &ownerID, &createdAt); err != nil {
+		return nil, err
+	}
+
+	c.Description = desc.String
+	if parentID.Valid {
+		c.ParentID = int(parentID.Int64)
+	}
+	if ownerID.Valid {
+		c.OwnerID = int(ownerID.Int64)
+	}
+	if createdAt.Valid {
+		c.CreatedAt = createdAt.Time
+	}
+	return &c, nil
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+	"supermarket-catalogue/internal/models"
+)
+
+func (pg *Postgres) GetIdentity(issuer, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	err := pg.db.QueryRow(`
+		SELECT user_id, issuer, subject, email, created_at
+		FROM user_identities
+		WHERE issuer = $1 AND subject = $2
+	`, issuer, subject).Scan(&identity.UserID, &identity.Issuer, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (pg *Postgres) ListIdentities(userID int) ([]models.Identity, error) {
+	rows, err := pg.db.Query(`
+		SELECT user_id, issuer, subject, email, created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at, issuer, subject
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []models.Identity{}
+	for rows.Next() {
+		var identity models.Identity
+		if err := rows.Scan(&identity.UserID, &identity.Issuer, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (pg *Postgres) LinkIdentity(identity *models.Identity) error {
+	return pg.db.QueryRow(`
+		INSERT INTO user_identities (user_id, issuer, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, identity.UserID, identity.Issuer, identity.Subject, identity.Email).Scan(&identity.CreatedAt)
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql"
+	"supermarket-catalogue/internal/models"
+	"time"
+)
+
+func scanLoginAttempts(row rowScanner) (*models.LoginAttempts, error) {
+	var a models.LoginAttempts
+	var lockedUntil sql.NullTime
+	if err := row.Scan(&a.Key, &a.Failures, &a.LastFailedAt, &lockedUntil); err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		a.LockedUntil = &lockedUntil.Time
+	}
+	return &a, nil
+}
+
+func (pg *Postgres) GetLoginAttempts(key string) (*models.LoginAttempts, error) {
+	a, err := scanLoginAttempts(pg.db.QueryRow(`
+		SELECT key, failures, last_failed_at, locked_until
+		FROM login_attempts
+		WHERE key = $1
+	`, key))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+func (pg *Postgres) RecordLoginFailure(key string, at time.Time, window time.Duration) (*models.LoginAttempts, error) {
+	return scanLoginAttempts(pg.db.QueryRow(`
+		INSERT INTO login_attempts (key, failures, last_failed_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failed_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			locked_until = CASE WHEN login_attempts.last_failed_at < $3 THEN NULL ELSE login_attempts.locked_until END,
+			last_failed_at = EXCLUDED.last_failed_at
+		RETURNING key, failures, last_failed_at, locked_until
+	`, key, at, at.Add(-window)))
+}
+
+func (pg *Postgres) LockLogin(key string, until time.Time) error {
+	return execOne(pg.db, `UPDATE login_attempts SET locked_until = $1 WHERE key = $2`, until, key)
+}
+
+func (pg *Postgres) ClearLoginAttempts(key string) error {
+	_, err := pg.db.Exec(`DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
@@ -0,0 +1,71 @@
+package repository
+
+import "supermarket-catalogue/internal/models"
+
+func (pg *Postgres) ManagesSupermarket(supermarketID, userID int) (bool, error) {
+	var manages bool
+	err := pg.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM supermarkets WHERE id = $1 AND owner_id = $2)
+			OR EXISTS (SELECT 1 FROM supermarket_managers WHERE supermarket_id = $1 AND user_id = $2)
+	`, supermarketID, userID).Scan(&manages)
+	return manages, err
+}
+
+func (pg *Postgres) ListSupermarketManagers(supermarketID int) ([]models.User, error) {
+	rows, err := pg.db.Query(`
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id IN (SELECT user_id FROM supermarket_managers WHERE supermarket_id = $1)
+		ORDER BY id
+	`, supermarketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (pg *Postgres) ListManagedSupermarkets(userID int) ([]models.Supermarket, error) {
+	rows, err := pg.db.Query(`
+		SELECT id, name, address, owner_id, created_at
+		FROM supermarkets
+		WHERE id IN (SELECT supermarket_id FROM supermarket_managers WHERE user_id = $1)
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.Supermarket{}
+	for rows.Next() {
+		s, err := scanSupermarket(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *s)
+	}
+	return items, rows.Err()
+}
+
+func (pg *Postgres) AssignManager(supermarketID, userID int) error {
+	_, err := pg.db.Exec(`
+		INSERT INTO supermarket_managers (supermarket_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, supermarketID, userID)
+	return err
+}
+
+func (pg *Postgres) UnassignManager(supermarketID, userID int) error {
+	return execOne(pg.db, `DELETE FROM supermarket_managers WHERE supermarket_id = $1 AND user_id = $2`, supermarketID, userID)
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"time"
+
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+func (s *Store) CreateAPIKey(k *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.apiKeys {
+		if existing.KeyHash == k.KeyHash {
+			return errDuplicate("api_keys", "key_hash")
+		}
+	}
+	if _, ok := s.supermarkets[k.SupermarketID]; k.SupermarketID != 0 && !ok {
+		return errMissing("supermarkets")
+	}
+	k.ID = s.newID("api_keys")
+	k.CreatedAt = now()
+	s.apiKeys[k.ID] = *k
+	return nil
+}
+
+func (s *Store) GetAPIKeyByHash(hash string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.apiKeys {
+		if k.KeyHash == hash {
+			return &k, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (s *Store) ListAPIKeys() ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := []models.APIKey{}
+	for _, id := range sortedKeys(s.apiKeys) {
+		keys = append(keys, s.apiKeys[id])
+	}
+	return keys, nil
+}
+
+func (s *Store) RevokeAPIKey(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.apiKeys[id]
+	if !ok || k.RevokedAt != nil {
+		return repository.ErrNotFound
+	}
+	revoked := now()
+	k.RevokedAt = &revoked
+	s.apiKeys[id] = k
+	return nil
+}
+
+func (s *Store) TouchAPIKey(id int, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.apiKeys[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	k.LastUsedAt = &at
+	s.apiKeys[id] = k
+	return nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"sort"
+
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+// ensureCatalogItem mirrors the Postgres upsert: the first product seen with
+// a barcode defines its item. Callers hold the write lock.
+func (s *Store) ensureCatalogItem(p *models.Product) {
+	p.ItemID = 0
+	if p.Barcode == "" {
+		return
+	}
+	if item, ok := s.itemByBarcode(p.Barcode); ok {
+		p.ItemID = item.ID
+		return
+	}
+	item := models.CatalogItem{
+		ID:         s.newID("catalog_items"),
+		Barcode:    p.Barcode,
+		Name:       p.Name,
+		Unit:       p.Unit,
+		CategoryID: p.CategoryID,
+		CreatedAt:  now(),
+	}
+	item.UpdatedAt = item.CreatedAt
+	s.items[item.ID] = item
+	p.ItemID = item.ID
+}
+
+func (s *Store) itemByBarcode(barcode string) (models.CatalogItem, bool) {
+	for _, item := range s.items {
+		if item.Barcode == barcode {
+			return item, true
+		}
+	}
+	return models.CatalogItem{}, false
+}
+
+func (s *Store) GetCatalogItem(barcode string) (*models.CatalogItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.itemByBarcode(barcode)
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	item.Offers = []models.Offer{}
+	for _, id := range sortedKeys(s.products) {
+		p := s.products[id]
+		if p.ItemID != item.ID {
+			continue
+		}
+		item.Offers = append(item.Offers, models.Offer{
+			ProductID:       p.ID,
+			SupermarketID:   p.SupermarketID,
+			SupermarketName: s.supermarkets[p.SupermarketID].Name,
+			Price:           p.Price,
+			UnitPrice:       p.UnitPrice,
+			Stock:           p.Stock,
+			LastUpdated:     p.LastUpdated,
+		})
+	}
+	sort.SliceStable(item.Offers, func(i, j int) bool {
+		a, b := item.Offers[i], item.Offers[j]
+		pa, pb := a.Price, b.Price
+		if a.UnitPrice != 0 {
+			pa = a.UnitPrice
+		}
+		if b.UnitPrice != 0 {
+			pb = b.UnitPrice
+		}
+		return pa < pb
+	})
+	return &item, nil
+}
+
+func (s *Store) UpdateCatalogItem(item *models.CatalogItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.itemByBarcode(item.Barcode)
+	if !ok {
+		return repository.ErrNotFound
+	}
+	item.ID = stored.ID
+	item.CreatedAt = stored.CreatedAt
+	item.UpdatedAt = now()
+	item.Offers = nil
+	s.items[item.ID] = *item
+	for id, p := range s.products {
+		if p.ItemID == item.ID {
+			p.Name, p.Unit, p.CategoryID = item.Name, item.Unit, item.CategoryID
+			s.products[id] = p
+		}
+	}
+	return nil
+}
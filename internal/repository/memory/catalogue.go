@@ -0,0 +1,694 @@
+package memory
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+func (s *Store) ListSupermarkets() ([]models.Supermarket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := []models.Supermarket{}
+	for _, id := range sortedKeys(s.supermarkets) {
+		items = append(items, s.supermarkets[id])
+	}
+	return items, nil
+}
+
+func (s *Store) GetSupermarket(id int) (*models.Supermarket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sm, ok := s.supermarkets[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &sm, nil
+}
+
+func (s *Store) CreateSupermarket(sm *models.Supermarket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sm.ID = s.newID("supermarkets")
+	sm.CreatedAt = now()
+	s.supermarkets[sm.ID] = *sm
+	return nil
+}
+
+func (s *Store) UpdateSupermarket(sm *models.Supermarket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.supermarkets[sm.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	sm.CreatedAt = old.CreatedAt
+	s.supermarkets[sm.ID] = *sm
+	return nil
+}
+
+func (s *Store) DeleteSupermarket(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.supermarkets[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, p := range s.products {
+		if p.SupermarketID == id {
+			return errReferenced("supermarkets", "products")
+		}
+	}
+	delete(s.supermarkets, id)
+	delete(s.managers, id)
+	for kid, k := range s.apiKeys {
+		if k.SupermarketID == id {
+			delete(s.apiKeys, kid)
+		}
+	}
+	for i := range s.history {
+		if s.history[i].supermarketID == id {
+			s.history[i].supermarketID = 0
+		}
+	}
+	return nil
+}
+
+func (s *Store) SupermarketStats() ([]models.SupermarketStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := []models.SupermarketStats{}
+	for _, id := range sortedKeys(s.supermarkets) {
+		st := models.SupermarketStats{SupermarketID: id, SupermarketName: s.supermarkets[id].Name}
+		sum := 0.0
+		for _, p := range s.products {
+			if p.SupermarketID != id {
+				continue
+			}
+			if st.ProductCount == 0 || p.Price < st.MinPrice {
+				st.MinPrice = p.Price
+			}
+			if st.ProductCount == 0 || p.Price > st.MaxPrice {
+				st.MaxPrice = p.Price
+			}
+			sum += p.Price
+			st.ProductCount++
+		}
+		if st.ProductCount > 0 {
+			st.AvgPrice = sum / float64(st.ProductCount)
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+func (s *Store) ListCategories() ([]models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := []models.Category{}
+	for _, c := range s.categories {
+		items = append(items, c)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+func (s *Store) GetCategory(id int) (*models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.categories[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &c, nil
+}
+
+func (s *Store) CategoryExists(id int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.categories[id]
+	return ok, nil
+}
+
+func (s *Store) CreateCategory(c *models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.siblingNamed(c.ParentID, c.Name, 0) {
+		return errDuplicate("categories", "name")
+	}
+	c.ID = s.newID("categories")
+	c.CreatedAt = now()
+	c.Children = nil
+	s.categories[c.ID] = *c
+	return nil
+}
+
+func (s *Store) UpdateCategory(c *models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.categories[c.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if s.siblingNamed(c.ParentID, c.Name, c.ID) {
+		return errDuplicate("categories", "name")
+	}
+	c.OwnerID = old.OwnerID
+	c.CreatedAt = old.CreatedAt
+	c.Children = nil
+	s.categories[c.ID] = *c
+	return nil
+}
+
+// siblingNamed reports whether a category other than except has name under
+// parentID, mirroring the per-parent unique index.
+func (s *Store) siblingNamed(parentID int, name string, except int) bool {
+	for _, other := range s.categories {
+		if other.ID != except && other.ParentID == parentID && other.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) DeleteCategory(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.categories[id]; !ok {
+		return repository.ErrNotFound
+	}
+	// Children become root categories, whose names must be unique.
+	for _, c := range s.categories {
+		if c.ParentID == id && s.siblingNamed(0, c.Name, id) {
+			return errDuplicate("categories", "name")
+		}
+	}
+	delete(s.categories, id)
+	for cid, c := range s.categories {
+		if c.ParentID == id {
+			c.ParentID = 0
+			s.categories[cid] = c
+		}
+	}
+	for pid, p := range s.products {
+		if p.CategoryID == id {
+			p.CategoryID = 0
+			s.products[pid] = p
+		}
+	}
+	for iid, item := range s.items {
+		if item.CategoryID == id {
+			item.CategoryID = 0
+			s.items[iid] = item
+		}
+	}
+	return nil
+}
+
+func (s *Store) CategorySubtree(id int) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.categories[id]; !ok {
+		return nil, repository.ErrNotFound
+	}
+	ids := []int{id}
+	for i := 0; i < len(ids); i++ {
+		for _, cid := range sortedKeys(s.categories) {
+			if s.categories[cid].ParentID == ids[i] {
+				ids = append(ids, cid)
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (s *Store) ListProducts(f repository.ProductFilter, page repository.Page) ([]models.Product, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := []models.Product{}
+	for _, id := range sortedKeys(s.products) {
+		p := s.products[id]
+		if matchesFilter(p, f) {
+			matched = append(matched, p)
+		}
+	}
+	sortProducts(matched, f)
+	return paginate(matched, page), len(matched), nil
+}
+
+// EachProduct works on a snapshot so fn may call back into the store.
+func (s *Store) EachProduct(f repository.ProductFilter, fn func(models.Product) error) error {
+	s.mu.RLock()
+	matched := []models.Product{}
+	for _, id := range sortedKeys(s.products) {
+		p := s.products[id]
+		if matchesFilter(p, f) {
+			matched = append(matched, p)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortProducts(matched, f)
+	for _, p := range matched {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchesFilter(p models.Product, f repository.ProductFilter) bool {
+	if f.Name != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(f.Name)) {
+		return false
+	}
+	if f.SupermarketID != 0 && p.SupermarketID != f.SupermarketID {
+		return false
+	}
+	if f.CategoryID != 0 && p.CategoryID != f.CategoryID {
+		return false
+	}
+	if f.CategoryIDs != nil && !containsInt(f.CategoryIDs, p.CategoryID) {
+		return false
+	}
+	if f.Barcode != "" && p.Barcode != f.Barcode {
+		return false
+	}
+	if f.Barcodes != nil && !containsString(f.Barcodes, p.Barcode) {
+		return false
+	}
+	if f.OwnerID != 0 && p.OwnerID != f.OwnerID {
+		return false
+	}
+	if f.MinPrice != nil && p.Price < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && p.Price > *f.MaxPrice {
+		return false
+	}
+	if f.InStock && p.Stock <= 0 {
+		return false
+	}
+	return true
+}
+
+func sortProducts(products []models.Product, f repository.ProductFilter) {
+	var less func(a, b models.Product) bool
+	switch f.Sort {
+	case "price":
+		less = func(a, b models.Product) bool { return a.Price < b.Price }
+	case "unit_price":
+		less = func(a, b models.Product) bool { return a.UnitPrice < b.UnitPrice }
+	case "name":
+		less = func(a, b models.Product) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case "last_updated":
+		less = func(a, b models.Product) bool { return a.LastUpdated.Before(b.LastUpdated) }
+	default:
+		less = func(a, b models.Product) bool { return a.ID < b.ID }
+	}
+	sort.SliceStable(products, func(i, j int) bool {
+		if f.Desc {
+			return less(products[j], products[i])
+		}
+		return less(products[i], products[j])
+	})
+}
+
+func paginate[T any](items []T, page repository.Page) []T {
+	if page.Offset >= len(items) {
+		return []T{}
+	}
+	end := len(items)
+	if page.Limit > 0 && page.Offset+page.Limit < end {
+		end = page.Offset + page.Limit
+	}
+	return items[page.Offset:end]
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchProducts approximates the PostgreSQL ranking: substring and word
+// matches score highest, then trigram similarity catches typos.
+func (s *Store) SearchProducts(q string, page repository.Page) ([]models.ProductSearchResult, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := strings.ToLower(strings.TrimSpace(q))
+	results := []models.ProductSearchResult{}
+	for _, id := range sortedKeys(s.products) {
+		p := s.products[id]
+		name := strings.ToLower(p.Name)
+		score := wordSimilarity(query, name)
+		if strings.Contains(name, query) {
+			score += 1
+		} else if score < 0.6 {
+			continue
+		}
+		results = append(results, models.ProductSearchResult{
+			Product:         p,
+			Score:           score,
+			HighlightedName: highlight(p.Name, strings.Fields(query)),
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return paginate(results, page), len(results), nil
+}
+
+// wordSimilarity is the share of the query's trigrams found in the best
+// matching word of text, in the spirit of pg_trgm's word_similarity.
+func wordSimilarity(query, text string) float64 {
+	qt := trigrams(query)
+	if len(qt) == 0 {
+		return 0
+	}
+	best := 0.0
+	for _, word := range strings.Fields(text) {
+		wt := trigrams(word)
+		shared := 0
+		for t := range qt {
+			if wt[t] {
+				shared++
+			}
+		}
+		best = math.Max(best, float64(shared)/float64(len(qt)))
+	}
+	return best
+}
+
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// highlight wraps the words of name found in words in <mark> tags. The rest
+// of the name is HTML-escaped, as the PostgreSQL store does.
+func highlight(name string, words []string) string {
+	lower := strings.ToLower(name)
+	marked := make([]bool, len(name))
+	for _, w := range words {
+		for start := 0; ; {
+			i := strings.Index(lower[start:], w)
+			if i < 0 || w == "" {
+				break
+			}
+			for j := start + i; j < start+i+len(w); j++ {
+				marked[j] = true
+			}
+			start += i + len(w)
+		}
+	}
+
+	var b strings.Builder
+	for start := 0; start < len(name); {
+		end := start
+		for end < len(name) && marked[end] == marked[start] {
+			end++
+		}
+		if marked[start] {
+			b.WriteString("<mark>" + html.EscapeString(name[start:end]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(name[start:end]))
+		}
+		start = end
+	}
+	return b.String()
+}
+
+func (s *Store) GetProduct(id int) (*models.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.products[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &p, nil
+}
+
+func (s *Store) CreateProduct(p *models.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ensureCatalogItem(p)
+	p.ID = s.newID("products")
+	p.LastUpdated = now()
+	s.products[p.ID] = *p
+	s.recordPrice(p)
+	return nil
+}
+
+func (s *Store) UpdateProduct(p *models.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.products[p.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	s.ensureCatalogItem(p)
+	p.LastUpdated = now()
+	s.products[p.ID] = *p
+	if repository.PriceChanged(old.Price, p.Price) || repository.PriceChanged(old.UnitPrice, p.UnitPrice) {
+		s.recordPrice(p)
+	}
+	return nil
+}
+
+func (s *Store) SaveProducts(products []*models.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range products {
+		if _, ok := s.products[p.ID]; p.ID != 0 && !ok {
+			return repository.ErrNotFound
+		}
+	}
+	for _, p := range products {
+		s.ensureCatalogItem(p)
+		old, exists := s.products[p.ID]
+		if !exists {
+			p.ID = s.newID("products")
+		}
+		p.LastUpdated = now()
+		s.products[p.ID] = *p
+		if !exists || repository.PriceChanged(old.Price, p.Price) || repository.PriceChanged(old.UnitPrice, p.UnitPrice) {
+			s.recordPrice(p)
+		}
+	}
+	return nil
+}
+
+func (s *Store) DeleteProduct(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.products[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(s.products, id)
+	for i := range s.history {
+		if s.history[i].productID == id {
+			s.history[i].productID = 0
+		}
+	}
+	return nil
+}
+
+func (s *Store) recordPrice(p *models.Product) {
+	s.history = append(s.history, priceRecord{
+		id:            s.newID("price_history"),
+		productID:     p.ID,
+		supermarketID: p.SupermarketID,
+		barcode:       p.Barcode,
+		point: models.PricePoint{
+			Price:      p.Price,
+			UnitPrice:  p.UnitPrice,
+			RecordedAt: p.LastUpdated,
+		},
+	})
+}
+
+func (s *Store) ProductHistory(productID int, from, to time.Time) ([]models.PricePoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.products[productID]; !ok {
+		return nil, repository.ErrNotFound
+	}
+	history := []models.PricePoint{}
+	for _, rec := range s.history {
+		if rec.productID == productID && inRange(rec.point.RecordedAt, from, to) {
+			history = append(history, rec.point)
+		}
+	}
+	return history, nil
+}
+
+func (s *Store) BarcodeHistory(barcode string, from, to time.Time) ([]models.PriceSeries, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bySupermarket := map[int]*models.PriceSeries{}
+	for _, rec := range s.history {
+		if rec.barcode != barcode || rec.supermarketID == 0 || !inRange(rec.point.RecordedAt, from, to) {
+			continue
+		}
+		series, ok := bySupermarket[rec.supermarketID]
+		if !ok {
+			series = &models.PriceSeries{
+				SupermarketID:   rec.supermarketID,
+				SupermarketName: s.supermarkets[rec.supermarketID].Name,
+			}
+			bySupermarket[rec.supermarketID] = series
+		}
+		series.History = append(series.History, rec.point)
+	}
+
+	result := []models.PriceSeries{}
+	for _, sid := range sortedKeys(bySupermarket) {
+		result = append(result, *bySupermarket[sid])
+	}
+	return result, nil
+}
+
+func inRange(t, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
+
+func (s *Store) OffersByBarcode(barcode string) ([]models.BarcodeOffer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	offers := []models.BarcodeOffer{}
+	for _, id := range sortedKeys(s.products) {
+		p := s.products[id]
+		if p.Barcode != barcode {
+			continue
+		}
+		o := models.BarcodeOffer{
+			ProductID: p.ID,
+			Name:      p.Name,
+			Price:     p.Price,
+			Unit:      p.Unit,
+		}
+		if item, ok := s.items[p.ItemID]; ok {
+			o.Name = item.Name
+			if item.Unit != "" {
+				o.Unit = item.Unit
+			}
+		}
+		if p.UnitPrice != 0 {
+			up := p.UnitPrice
+			o.UnitPrice = &up
+		}
+		if p.SupermarketID != 0 {
+			sid := p.SupermarketID
+			o.SupermarketID = &sid
+			o.SupermarketName = s.supermarkets[sid].Name
+		}
+		if !p.LastUpdated.IsZero() {
+			lu := p.LastUpdated.UTC().Format("2006-01-02T15:04:05Z")
+			o.LastUpdated = &lu
+		}
+		offers = append(offers, o)
+	}
+	sort.SliceStable(offers, func(i, j int) bool {
+		a, b := offers[i], offers[j]
+		if (a.UnitPrice == nil) != (b.UnitPrice == nil) {
+			return a.UnitPrice != nil
+		}
+		if a.UnitPrice != nil && *a.UnitPrice != *b.UnitPrice {
+			return *a.UnitPrice < *b.UnitPrice
+		}
+		return a.Price < b.Price
+	})
+	return offers, nil
+}
+
+// effectivePrice is the per-unit price when one is set, else the shelf price.
+func effectivePrice(p models.Product) float64 {
+	if p.UnitPrice != 0 {
+		return p.UnitPrice
+	}
+	return p.Price
+}
+
+func (s *Store) CheapestOffers(barcodes []string) (map[string]map[int]float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	prices := map[string]map[int]float64{}
+	for _, bc := range barcodes {
+		prices[bc] = map[int]float64{}
+	}
+	for _, p := range s.products {
+		offers, wanted := prices[p.Barcode]
+		if !wanted || p.SupermarketID == 0 {
+			continue
+		}
+		price := effectivePrice(p)
+		if cur, ok := offers[p.SupermarketID]; !ok || price < cur {
+			offers[p.SupermarketID] = price
+		}
+	}
+	return prices, nil
+}
+
+func (s *Store) CheapestSubstitutes(barcodes []string) (map[string]map[int]models.Substitute, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subs := map[string]map[int]models.Substitute{}
+	for _, bc := range barcodes {
+		// The most recently updated categorised listing defines what the
+		// barcode is.
+		var wanted *models.Product
+		for _, id := range sortedKeys(s.products) {
+			p := s.products[id]
+			if p.Barcode == bc && p.CategoryID != 0 && (wanted == nil || p.LastUpdated.After(wanted.LastUpdated)) {
+				wanted = &p
+			}
+		}
+		if wanted == nil {
+			continue
+		}
+
+		for _, id := range sortedKeys(s.products) {
+			p := s.products[id]
+			if p.SupermarketID == 0 || p.Barcode == bc || p.CategoryID != wanted.CategoryID || p.Unit != wanted.Unit {
+				continue
+			}
+			if subs[bc] == nil {
+				subs[bc] = map[int]models.Substitute{}
+			}
+			price := effectivePrice(p)
+			if cur, ok := subs[bc][p.SupermarketID]; ok && cur.Price <= price {
+				continue
+			}
+			subs[bc][p.SupermarketID] = models.Substitute{
+				ForBarcode: bc,
+				ProductID:  p.ID,
+				Name:       p.Name,
+				Barcode:    p.Barcode,
+				Price:      price,
+			}
+		}
+	}
+	return subs, nil
+}
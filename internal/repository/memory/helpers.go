@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	"supermarket-catalogue/internal/repository"
+)
+
+// errDuplicate mirrors a unique-constraint violation.
+func errDuplicate(table, column string) error {
+	return fmt.Errorf("%w: duplicate key value violates unique constraint on %s.%s", repository.ErrConflict, table, column)
+}
+
+// errReferenced mirrors a foreign-key violation on delete.
+func errReferenced(table, referencedBy string) error {
+	return fmt.Errorf("%s row is still referenced from %s", table, referencedBy)
+}
+
+// errMissing mirrors a foreign-key violation on insert.
+func errMissing(table string) error {
+	return fmt.Errorf("referenced %s row does not exist", table)
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"sort"
+
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+type identityKey struct {
+	issuer, subject string
+}
+
+func (s *Store) GetIdentity(issuer, subject string) (*models.Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	identity, ok := s.identities[identityKey{issuer, subject}]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &identity, nil
+}
+
+func (s *Store) ListIdentities(userID int) ([]models.Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	identities := []models.Identity{}
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		a, b := identities[i], identities[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.Issuer+" "+a.Subject < b.Issuer+" "+b.Subject
+	})
+	return identities, nil
+}
+
+func (s *Store) LinkIdentity(identity *models.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := identityKey{identity.Issuer, identity.Subject}
+	if _, ok := s.identities[key]; ok {
+		return errDuplicate("user_identities", "issuer, subject")
+	}
+	if _, ok := s.users[identity.UserID]; !ok {
+		return errMissing("users")
+	}
+	identity.CreatedAt = now()
+	s.identities[key] = *identity
+	return nil
+}
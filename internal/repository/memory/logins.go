@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"time"
+
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+func (s *Store) GetLoginAttempts(key string) (*models.LoginAttempts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.logins[key]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &a, nil
+}
+
+func (s *Store) RecordLoginFailure(key string, at time.Time, window time.Duration) (*models.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.logins[key]
+	if !ok || a.LastFailedAt.Before(at.Add(-window)) {
+		a = models.LoginAttempts{Key: key}
+	}
+	a.Failures++
+	a.LastFailedAt = at
+	s.logins[key] = a
+	return &a, nil
+}
+
+func (s *Store) LockLogin(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.logins[key]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	a.LockedUntil = &until
+	s.logins[key] = a
+	return nil
+}
+
+func (s *Store) ClearLoginAttempts(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.logins, key)
+	return nil
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+func (s *Store) ManagesSupermarket(supermarketID, userID int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sm, ok := s.supermarkets[supermarketID]
+	if !ok {
+		return false, nil
+	}
+	return sm.OwnerID == userID || s.managers[supermarketID][userID], nil
+}
+
+func (s *Store) ListSupermarketManagers(supermarketID int) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := []models.User{}
+	for _, id := range sortedKeys(s.managers[supermarketID]) {
+		u := s.users[id]
+		u.Password = ""
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (s *Store) ListManagedSupermarkets(userID int) ([]models.Supermarket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := []models.Supermarket{}
+	for _, id := range sortedKeys(s.managers) {
+		if s.managers[id][userID] {
+			items = append(items, s.supermarkets[id])
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) AssignManager(supermarketID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.supermarkets[supermarketID]; !ok {
+		return errMissing("supermarkets")
+	}
+	if _, ok := s.users[userID]; !ok {
+		return errMissing("users")
+	}
+	if s.managers[supermarketID] == nil {
+		s.managers[supermarketID] = map[int]bool{}
+	}
+	s.managers[supermarketID][userID] = true
+	return nil
+}
+
+func (s *Store) UnassignManager(supermarketID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.managers[supermarketID][userID] {
+		return repository.ErrNotFound
+	}
+	delete(s.managers[supermarketID], userID)
+	return nil
+}
@@ -0,0 +1,311 @@
+// Package memory provides an in-process implementation of every repository
+// store, for running the HTTP API in tests and demos without PostgreSQL.
+// Data lives only as long as the Store.
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"supermarket-catalogue/internal/auth"
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+var (
+	_ repository.ProductStore      = (*Store)(nil)
+	_ repository.CatalogStore      = (*Store)(nil)
+	_ repository.SupermarketStore  = (*Store)(nil)
+	_ repository.CategoryStore     = (*Store)(nil)
+	_ repository.UserStore         = (*Store)(nil)
+	_ repository.TokenStore        = (*Store)(nil)
+	_ repository.LoginAttemptStore = (*Store)(nil)
+	_ repository.TwoFactorStore    = (*Store)(nil)
+	_ repository.APIKeyStore       = (*Store)(nil)
+	_ repository.IdentityStore     = (*Store)(nil)
+)
+
+type priceRecord struct {
+	id            int
+	productID     int
+	supermarketID int
+	barcode       string
+	point         models.PricePoint
+}
+
+// Store keeps every table in maps guarded by a single mutex.
+type Store struct {
+	mu sync.RWMutex
+
+	users        map[int]models.User
+	supermarkets map[int]models.Supermarket
+	categories   map[int]models.Category
+	products     map[int]models.Product
+	items        map[int]models.CatalogItem
+	history      []priceRecord
+	// managers maps supermarket IDs to the set of assigned user IDs.
+	managers map[int]map[int]bool
+
+	// refreshTokens is keyed by token hash, revokedTokens maps access
+	// token IDs to their expiry.
+	refreshTokens map[string]models.RefreshToken
+	revokedTokens map[string]time.Time
+	// logins holds failed login counters by throttle key.
+	logins map[string]models.LoginAttempts
+	// totp holds two-factor secrets by user ID, recoveryCodes maps each
+	// user's code hashes to whether they have been used.
+	totp          map[int]models.TOTP
+	recoveryCodes map[int]map[string]bool
+	apiKeys       map[int]models.APIKey
+	// identities is keyed by issuer and subject.
+	identities map[identityKey]models.Identity
+
+	nextID map[string]int
+}
+
+// New returns an empty store seeded with the default admin account
+// (admin@example.com / admin123).
+func New() *Store {
+	s := &Store{
+		users:        map[int]models.User{},
+		supermarkets: map[int]models.Supermarket{},
+		categories:   map[int]models.Category{},
+		products:     map[int]models.Product{},
+		items:        map[int]models.CatalogItem{},
+		managers:     map[int]map[int]bool{},
+
+		refreshTokens: map[string]models.RefreshToken{},
+		revokedTokens: map[string]time.Time{},
+		logins:        map[string]models.LoginAttempts{},
+		totp:          map[int]models.TOTP{},
+		recoveryCodes: map[int]map[string]bool{},
+		apiKeys:       map[int]models.APIKey{},
+		identities:    map[identityKey]models.Identity{},
+		nextID:        map[string]int{},
+	}
+	admin := models.User{Name: "Admin", Email: "admin@example.com", Password: "admin123", Role: "admin", EmailVerified: true}
+	if err := s.CreateUser(&admin); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewStores wires a fresh Store into every slot of repository.Stores.
+func NewStores() *repository.Stores {
+	s := New()
+	return &repository.Stores{
+		Products:     s,
+		Catalog:      s,
+		Supermarkets: s,
+		Categories:   s,
+		Users:        s,
+		Tokens:       s,
+		Logins:       s,
+		TwoFactor:    s,
+		APIKeys:      s,
+		Identities:   s,
+	}
+}
+
+func (s *Store) newID(table string) int {
+	s.nextID[table]++
+	return s.nextID[table]
+}
+
+// now truncates to microseconds, the precision PostgreSQL stores.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (s *Store) CreateUser(user *models.User) error {
+	hashed, err := auth.HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.Email == user.Email {
+			return errDuplicate("users", "email")
+		}
+	}
+	user.ID = s.newID("users")
+	user.CreatedAt = now()
+	stored := *user
+	stored.Password = hashed
+	s.users[user.ID] = stored
+	return nil
+}
+
+func (s *Store) GetUserByEmail(email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (s *Store) GetUserByID(id int) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	u.Password = ""
+	return &u, nil
+}
+
+func (s *Store) ListUsers() ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := []models.User{}
+	for _, id := range sortedKeys(s.users) {
+		u := s.users[id]
+		u.Password = ""
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (s *Store) UpdatePassword(id int, password string) error {
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return s.updateUser(id, func(u *models.User) { u.Password = hashed })
+}
+
+func (s *Store) SetEmailVerified(id int) error {
+	return s.updateUser(id, func(u *models.User) { u.EmailVerified = true })
+}
+
+func (s *Store) UpdateProfile(id int, name, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for _, other := range s.users {
+		if other.ID != id && other.Email == email {
+			return errDuplicate("users", "email")
+		}
+	}
+	if u.Email != email {
+		u.EmailVerified = false
+	}
+	u.Name = name
+	u.Email = email
+	s.users[id] = u
+	return nil
+}
+
+func (s *Store) UpdateUserRole(id int, role string) error {
+	return s.updateUser(id, func(u *models.User) { u.Role = role })
+}
+
+func (s *Store) SetUserDisabled(id int, disabled bool) error {
+	return s.updateUser(id, func(u *models.User) { u.Disabled = disabled })
+}
+
+func (s *Store) updateUser(id int, change func(*models.User)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	change(&u)
+	s.users[id] = u
+	return nil
+}
+
+func (s *Store) EraseUser(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok || u.Erased {
+		return repository.ErrNotFound
+	}
+	u.Name = models.ErasedUserName
+	u.Email = fmt.Sprintf("erased-%d@invalid", id)
+	u.Password = ""
+	u.EmailVerified = false
+	u.Disabled = true
+	u.TOTPEnabled = false
+	u.Erased = true
+	s.users[id] = u
+
+	delete(s.totp, id)
+	delete(s.recoveryCodes, id)
+	for key, identity := range s.identities {
+		if identity.UserID == id {
+			delete(s.identities, key)
+		}
+	}
+	for hash, t := range s.refreshTokens {
+		if t.UserID == id {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for _, users := range s.managers {
+		delete(users, id)
+	}
+	return nil
+}
+
+func (s *Store) DeleteUser(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(s.users, id)
+	for pid, p := range s.products {
+		if p.OwnerID == id {
+			p.OwnerID = 0
+			s.products[pid] = p
+		}
+	}
+	for sid, sm := range s.supermarkets {
+		if sm.OwnerID == id {
+			sm.OwnerID = 0
+			s.supermarkets[sid] = sm
+		}
+	}
+	for cid, c := range s.categories {
+		if c.OwnerID == id {
+			c.OwnerID = 0
+			s.categories[cid] = c
+		}
+	}
+	for _, users := range s.managers {
+		delete(users, id)
+	}
+	delete(s.totp, id)
+	delete(s.recoveryCodes, id)
+	for kid, k := range s.apiKeys {
+		if k.CreatedBy == id {
+			k.CreatedBy = 0
+			s.apiKeys[kid] = k
+		}
+	}
+	for hash, t := range s.refreshTokens {
+		if t.UserID == id {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for key, identity := range s.identities {
+		if identity.UserID == id {
+			delete(s.identities, key)
+		}
+	}
+	return nil
+}
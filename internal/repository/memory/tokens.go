@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"time"
+
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+func (s *Store) CreateRefreshToken(t *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.refreshTokens[t.TokenHash]; ok {
+		return errDuplicate("refresh_tokens", "token_hash")
+	}
+	t.ID = s.newID("refresh_tokens")
+	t.CreatedAt = now()
+	s.refreshTokens[t.TokenHash] = *t
+	return nil
+}
+
+func (s *Store) ConsumeRefreshToken(hash string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	if t.UsedAt != nil {
+		s.revokeWhere(func(rt models.RefreshToken) bool { return rt.FamilyID == t.FamilyID })
+		return nil, repository.ErrTokenReused
+	}
+	used := now()
+	t.UsedAt = &used
+	s.refreshTokens[hash] = t
+	return &t, nil
+}
+
+func (s *Store) RevokeFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeWhere(func(t models.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (s *Store) RevokeUserTokens(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokeWhere(func(t models.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+// revokeWhere revokes the matching refresh tokens. Callers hold the write
+// lock.
+func (s *Store) revokeWhere(match func(models.RefreshToken) bool) {
+	revoked := now()
+	for hash, t := range s.refreshTokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &revoked
+			s.refreshTokens[hash] = t
+		}
+	}
+}
+
+func (s *Store) RevokeAccessToken(jti string, userID int, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := now()
+	for id, exp := range s.revokedTokens {
+		if exp.Before(cutoff) {
+			delete(s.revokedTokens, id)
+		}
+	}
+	s.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (s *Store) ConsumeTokenID(jti string, userID int, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, spent := s.revokedTokens[jti]; spent {
+		return false, nil
+	}
+	s.revokedTokens[jti] = expiresAt
+	return true, nil
+}
+
+func (s *Store) IsRevoked(jti, familyID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.revokedTokens[jti]; ok {
+		return true, nil
+	}
+	for _, t := range s.refreshTokens {
+		if t.FamilyID == familyID && t.RevokedAt != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
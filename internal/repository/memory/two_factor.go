@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"supermarket-catalogue/internal/models"
+	"supermarket-catalogue/internal/repository"
+)
+
+func (s *Store) GetTOTP(userID int) (*models.TOTP, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.totp[userID]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	for _, used := range s.recoveryCodes[userID] {
+		if !used {
+			t.RecoveryCodesLeft++
+		}
+	}
+	return &t, nil
+}
+
+func (s *Store) SetPendingTOTP(userID int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.TOTPEnabled = false
+	s.users[userID] = u
+	s.totp[userID] = models.TOTP{Secret: secret}
+	return nil
+}
+
+func (s *Store) EnableTOTP(userID int, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.totp[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	t.Enabled = true
+	s.totp[userID] = t
+	u := s.users[userID]
+	u.TOTPEnabled = true
+	s.users[userID] = u
+	s.setRecoveryCodes(userID, recoveryCodeHashes)
+	return nil
+}
+
+func (s *Store) DisableTOTP(userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	u.TOTPEnabled = false
+	s.users[userID] = u
+	delete(s.totp, userID)
+	delete(s.recoveryCodes, userID)
+	return nil
+}
+
+func (s *Store) UseTOTPStep(userID int, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.totp[userID]
+	if !ok || step <= t.LastStep {
+		return false, nil
+	}
+	t.LastStep = step
+	s.totp[userID] = t
+	return true, nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(userID int, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setRecoveryCodes(userID, hashes)
+	return nil
+}
+
+func (s *Store) setRecoveryCodes(userID int, hashes []string) {
+	codes := map[string]bool{}
+	for _, hash := range hashes {
+		codes[hash] = false
+	}
+	s.recoveryCodes[userID] = codes
+}
+
+func (s *Store) ConsumeRecoveryCode(userID int, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, ok := s.recoveryCodes[userID][hash]
+	if !ok || used {
+		return false, nil
+	}
+	s.recoveryCodes[userID][hash] = true
+	return true, nil
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS supermarkets;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,38 @@
+-- Baseline schema. Written with IF NOT EXISTS so databases created before
+-- migrations existed can adopt it without changes.
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	email VARCHAR(100) UNIQUE NOT NULL,
+	password TEXT NOT NULL,
+	role VARCHAR(20) DEFAULT 'user',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO users (name, email, password, role)
+VALUES ('Admin', 'admin@example.com', '$2a$10$2cJhQ6Q5bVK9z7Q8q5Z5/.JhQ6Q5bVK9z7Q8q5Z5/.JhQ6Q5bVK9z7Q8q5Z5/', 'admin')
+ON CONFLICT (email) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS supermarkets (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	address TEXT,
+	owner_id INTEGER REFERENCES users(id),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	price DECIMAL(10,2) NOT NULL,
+	stock INTEGER NOT NULL,
+	image TEXT,
+	category_id INTEGER,
+	owner_id INTEGER REFERENCES users(id),
+	supermarket_id INTEGER REFERENCES supermarkets(id),
+	barcode VARCHAR(100),
+	unit VARCHAR(50),
+	unit_price DECIMAL(10,2),
+	last_updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
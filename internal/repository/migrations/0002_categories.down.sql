@@ -0,0 +1,2 @@
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_category_id_fkey;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS categories (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) UNIQUE NOT NULL,
+	description TEXT,
+	parent_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+	owner_id INTEGER REFERENCES users(id),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE categories
+	ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+
+-- category_id used to be a bare integer: drop dangling references before
+-- attaching the foreign key.
+DO $$
+BEGIN
+	IF NOT EXISTS (
+		SELECT 1 FROM pg_constraint WHERE conname = 'products_category_id_fkey'
+	) THEN
+		UPDATE products SET category_id = NULL
+		WHERE category_id IS NOT NULL
+		  AND category_id NOT IN (SELECT id FROM categories);
+		ALTER TABLE products
+			ADD CONSTRAINT products_category_id_fkey
+			FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL;
+	END IF;
+END $$;
@@ -0,0 +1,5 @@
+-- pg_trgm stays installed: other schemas of the database may use it, and
+-- CREATE EXTENSION IF NOT EXISTS makes reapplying 0003 harmless.
+DROP INDEX IF EXISTS products_barcode_idx;
+DROP INDEX IF EXISTS products_name_trgm_idx;
+DROP INDEX IF EXISTS products_name_fts_idx;
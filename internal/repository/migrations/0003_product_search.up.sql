@@ -0,0 +1,10 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS products_name_fts_idx
+	ON products USING GIN (to_tsvector('simple', name));
+
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx
+	ON products USING GIN (name gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS products_barcode_idx
+	ON products (barcode, supermarket_id);
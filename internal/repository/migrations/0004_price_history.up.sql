@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS price_history (
+	id SERIAL PRIMARY KEY,
+	product_id INTEGER REFERENCES products(id) ON DELETE SET NULL,
+	supermarket_id INTEGER REFERENCES supermarkets(id) ON DELETE SET NULL,
+	barcode VARCHAR(100),
+	price DECIMAL(10,2) NOT NULL,
+	unit_price DECIMAL(10,2),
+	recorded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS price_history_product_idx ON price_history (product_id, recorded_at);
+CREATE INDEX IF NOT EXISTS price_history_barcode_idx ON price_history (barcode, recorded_at);
+
+-- Products that predate price_history get their current price as the first
+-- point of their timeline.
+INSERT INTO price_history (product_id, supermarket_id, barcode, price, unit_price, recorded_at)
+SELECT p.id, p.supermarket_id, p.barcode, p.price, p.unit_price, COALESCE(p.last_updated, CURRENT_TIMESTAMP)
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM price_history h WHERE h.product_id = p.id);
@@ -0,0 +1,4 @@
+DROP VIEW IF EXISTS offers;
+DROP INDEX IF EXISTS products_item_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS item_id;
+DROP TABLE IF EXISTS catalog_items;
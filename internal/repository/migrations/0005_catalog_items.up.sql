@@ -0,0 +1,36 @@
+-- catalog_items holds what a barcode is; each products row is one
+-- supermarket's offer of it.
+CREATE TABLE IF NOT EXISTS catalog_items (
+	id SERIAL PRIMARY KEY,
+	barcode VARCHAR(100) UNIQUE NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	brand VARCHAR(255),
+	unit VARCHAR(50),
+	category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE products
+	ADD COLUMN IF NOT EXISTS item_id INTEGER REFERENCES catalog_items(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS products_item_idx ON products (item_id);
+
+-- Existing barcodes take the name, unit and category of their most recently
+-- updated listing.
+INSERT INTO catalog_items (barcode, name, unit, category_id)
+SELECT DISTINCT ON (barcode) barcode, name, unit, category_id
+FROM products
+WHERE barcode IS NOT NULL AND barcode <> ''
+ORDER BY barcode, last_updated DESC NULLS LAST, id DESC
+ON CONFLICT (barcode) DO NOTHING;
+
+UPDATE products p
+SET item_id = c.id
+FROM catalog_items c
+WHERE p.barcode = c.barcode AND p.item_id IS NULL;
+
+CREATE OR REPLACE VIEW offers AS
+SELECT id AS product_id, item_id, supermarket_id, price, unit_price, stock, last_updated
+FROM products
+WHERE item_id IS NOT NULL;
@@ -0,0 +1,9 @@
+-- Users assigned to maintain a supermarket's products besides its owner.
+CREATE TABLE IF NOT EXISTS supermarket_managers (
+	supermarket_id INTEGER NOT NULL REFERENCES supermarkets(id) ON DELETE CASCADE,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (supermarket_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS supermarket_managers_user_idx ON supermarket_managers (user_id);
@@ -0,0 +1,8 @@
+-- Consecutive failed logins per throttle key ("account:<email>" or
+-- "ip:<address>"). A row is removed by a successful login or an unlock.
+CREATE TABLE IF NOT EXISTS login_attempts (
+	key VARCHAR(320) PRIMARY KEY,
+	failures INTEGER NOT NULL,
+	last_failed_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP
+);
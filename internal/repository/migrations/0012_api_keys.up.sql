@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	key_hash CHAR(64) UNIQUE NOT NULL,
+	scopes TEXT[] NOT NULL,
+	supermarket_id INTEGER REFERENCES supermarkets(id) ON DELETE CASCADE,
+	created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Erased accounts keep their row, stripped of personal data, so records
+-- they own still reference a user.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP;
@@ -0,0 +1,4 @@
+-- Fails while two categories share a name; rename them first.
+DROP INDEX IF EXISTS categories_root_name_key;
+DROP INDEX IF EXISTS categories_parent_id_name_key;
+ALTER TABLE categories ADD CONSTRAINT categories_name_key UNIQUE (name);
@@ -0,0 +1,6 @@
+-- Category names only need to be unique among siblings, so the same
+-- subcategory, e.g. "Organic", can sit under several parents. NULL parents
+-- never compare equal, so root categories get an index of their own.
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS categories_parent_id_name_key ON categories (parent_id, name);
+CREATE UNIQUE INDEX IF NOT EXISTS categories_root_name_key ON categories (name) WHERE parent_id IS NULL;
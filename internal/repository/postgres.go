@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Postgres implements every store on top of a PostgreSQL database.
+type Postgres struct {
+	db *sql.DB
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// NewPostgresStores wires a Postgres store into every slot of Stores.
+func NewPostgresStores(db *sql.DB) *Stores {
+	pg := NewPostgres(db)
+	return &Stores{
+		Products:     pg,
+		Catalog:      pg,
+		Supermarkets: pg,
+		Categories:   pg,
+		Users:        pg,
+		Tokens:       pg,
+		Logins:       pg,
+		TwoFactor:    pg,
+		APIKeys:      pg,
+		Identities:   pg,
+	}
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// nullableID maps the zero value of an optional foreign key to SQL NULL.
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+var (
+	_ ProductStore      = (*Postgres)(nil)
+	_ CatalogStore      = (*Postgres)(nil)
+	_ SupermarketStore  = (*Postgres)(nil)
+	_ CategoryStore     = (*Postgres)(nil)
+	_ UserStore         = (*Postgres)(nil)
+	_ TokenStore        = (*Postgres)(nil)
+	_ LoginAttemptStore = (*Postgres)(nil)
+	_ TwoFactorStore    = (*Postgres)(nil)
+	_ APIKeyStore       = (*Postgres)(nil)
+	_ IdentityStore     = (*Postgres)(nil)
+)
+
+// conflict maps a unique-constraint violation to ErrConflict and returns any
+// other error unchanged.
+func conflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("%w: %s", ErrConflict, pqErr.Constraint)
+	}
+	return err
+}
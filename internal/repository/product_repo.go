@@ -0,0 +1,572 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"supermarket-catalogue/internal/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// productColumns is the select list understood by scanProduct.
+const productColumns = `id, name, price, stock, image, category_id, owner_id, supermarket_id, barcode, unit, unit_price, last_updated, created_at, item_id`
+
+// productSortColumns maps ProductFilter.Sort to its ORDER BY expression.
+var productSortColumns = map[string]string{
+	"price":        "price",
+	"unit_price":   "unit_price",
+	"name":         "LOWER(name)",
+	"last_updated": "last_updated",
+}
+
+// searchMatch matches products whose name contains the words of $1 or is
+// close to it by trigram word similarity, so typos like "chees" still hit.
+const searchMatch = `
+	to_tsvector('simple', name) @@ plainto_tsquery('simple', $1)
+	OR $1 <% name
+	OR name ILIKE '%' || $2 || '%'
+`
+
+// ts_headline does not escape the text around matches, so it marks them with
+// control characters, stripped from the name beforehand, which
+// escapeHighlight turns into <mark> tags once the name is HTML-escaped.
+const (
+	highlightStart  = "\x01"
+	highlightStop   = "\x02"
+	headlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+)
+
+// escapeHighlight makes a ts_headline result safe to render as HTML.
+func escapeHighlight(headline string) string {
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(html.EscapeString(headline))
+}
+
+// productQuery accumulates WHERE conditions and their positional arguments.
+type productQuery struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (q *productQuery) add(condition string, arg interface{}) {
+	q.args = append(q.args, arg)
+	q.conditions = append(q.conditions, fmt.Sprintf(condition, "$"+strconv.Itoa(len(q.args))))
+}
+
+func (q *productQuery) where() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+func (q *productQuery) nextParam(offset int) string {
+	return "$" + strconv.Itoa(len(q.args)+offset)
+}
+
+func buildProductQuery(f ProductFilter) *productQuery {
+	q := &productQuery{}
+	if f.Name != "" {
+		q.add("name ILIKE %s", "%"+escapeLike(f.Name)+"%")
+	}
+	if f.SupermarketID != 0 {
+		q.add("supermarket_id = %s", f.SupermarketID)
+	}
+	if f.CategoryID != 0 {
+		q.add("category_id = %s", f.CategoryID)
+	}
+	if f.CategoryIDs != nil {
+		q.add("category_id = ANY(%s)", pq.Array(f.CategoryIDs))
+	}
+	if f.Barcode != "" {
+		q.add("barcode = %s", f.Barcode)
+	}
+	if f.Barcodes != nil {
+		q.add("barcode = ANY(%s)", pq.Array(f.Barcodes))
+	}
+	if f.OwnerID != 0 {
+		q.add("owner_id = %s", f.OwnerID)
+	}
+	if f.MinPrice != nil {
+		q.add("price >= %s", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		q.add("price <= %s", *f.MaxPrice)
+	}
+	if f.InStock {
+		q.conditions = append(q.conditions, "stock > 0")
+	}
+	return q
+}
+
+func productOrderBy(f ProductFilter) string {
+	direction := "ASC"
+	if f.Desc {
+		direction = "DESC"
+	}
+	column, ok := productSortColumns[f.Sort]
+	if !ok {
+		return "id " + direction
+	}
+	// id keeps pages stable when several products share a sort value.
+	return column + " " + direction + " NULLS LAST, id"
+}
+
+// escapeLike escapes the LIKE wildcards in user input.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (pg *Postgres) ListProducts(f ProductFilter, page Page) ([]models.Product, int, error) {
+	q := buildProductQuery(f)
+
+	rows, err := pg.db.Query(`
+		SELECT `+productColumns+`
+		FROM products
+		`+q.where()+`
+		ORDER BY `+productOrderBy(f)+`
+		LIMIT `+q.nextParam(1)+` OFFSET `+q.nextParam(2),
+		append(q.args, page.Limit, page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	products, err := scanProducts(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err = pg.db.QueryRow(`SELECT COUNT(*) FROM products `+q.where(), q.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+func (pg *Postgres) EachProduct(f ProductFilter, fn func(models.Product) error) error {
+	q := buildProductQuery(f)
+	rows, err := pg.db.Query(`
+		SELECT `+productColumns+`
+		FROM products
+		`+q.where()+`
+		ORDER BY `+productOrderBy(f), q.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (pg *Postgres) SearchProducts(query string, page Page) ([]models.ProductSearchResult, int, error) {
+	rows, err := pg.db.Query(`
+		SELECT `+productColumns+`,
+			ts_rank(to_tsvector('simple', name), plainto_tsquery('simple', $1))
+				+ word_similarity($1, name) AS score,
+			ts_headline('simple', translate(name, $6, ''), plainto_tsquery('simple', $1), $5) AS highlighted_name
+		FROM products
+		WHERE `+searchMatch+`
+		ORDER BY score DESC, id
+		LIMIT $3 OFFSET $4
+	`, query, escapeLike(query), page.Limit, page.Offset, headlineOptions, highlightStart+highlightStop)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []models.ProductSearchResult{}
+	for rows.Next() {
+		var res models.ProductSearchResult
+		res.Product, err = scanProduct(rows, &res.Score, &res.HighlightedName)
+		if err != nil {
+			return nil, 0, err
+		}
+		res.HighlightedName = escapeHighlight(res.HighlightedName)
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	err = pg.db.QueryRow(`SELECT COUNT(*) FROM products WHERE `+searchMatch, query, escapeLike(query)).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+func (pg *Postgres) GetProduct(id int) (*models.Product, error) {
+	p, err := scanProduct(pg.db.QueryRow(`SELECT `+productColumns+` FROM products WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (pg *Postgres) CreateProduct(p *models.Product) error {
+	return inTx(pg.db, func(tx *sql.Tx) error { return createProduct(tx, p) })
+}
+
+func (pg *Postgres) UpdateProduct(p *models.Product) error {
+	return inTx(pg.db, func(tx *sql.Tx) error { return updateProduct(tx, p) })
+}
+
+// SaveProducts creates the products without an ID and updates the others,
+// all in one transaction.
+func (pg *Postgres) SaveProducts(products []*models.Product) error {
+	return inTx(pg.db, func(tx *sql.Tx) error {
+		for _, p := range products {
+			save := updateProduct
+			if p.ID == 0 {
+				save = createProduct
+			}
+			if err := save(tx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func createProduct(tx *sql.Tx, p *models.Product) error {
+	if err := ensureCatalogItem(tx, p); err != nil {
+		return err
+	}
+	err := tx.QueryRow(`
+		INSERT INTO products (name, price, stock, image, category_id, owner_id, supermarket_id, barcode, unit, unit_price, item_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, last_updated
+	`,
+		p.Name, p.Price, p.Stock, p.Image, nullableID(p.CategoryID), nullableID(p.OwnerID),
+		p.SupermarketID, p.Barcode, p.Unit, p.UnitPrice, nullableID(p.ItemID),
+	).Scan(&p.ID, &p.LastUpdated)
+	if err != nil {
+		return err
+	}
+	return recordPrice(tx, p)
+}
+
+func updateProduct(tx *sql.Tx, p *models.Product) error {
+	var oldPrice float64
+	var oldUnitPrice sql.NullFloat64
+	err := tx.QueryRow(`SELECT price, unit_price FROM products WHERE id = $1 FOR UPDATE`, p.ID).
+		Scan(&oldPrice, &oldUnitPrice)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := ensureCatalogItem(tx, p); err != nil {
+		return err
+	}
+	err = tx.QueryRow(`
+		UPDATE products
+		SET name = $1, price = $2, stock = $3, image = $4, category_id = $5, owner_id = $6, supermarket_id = $7, barcode = $8, unit = $9, unit_price = $10,
+			item_id = $11, last_updated = CURRENT_TIMESTAMP
+		WHERE id = $12
+		RETURNING last_updated
+	`,
+		p.Name, p.Price, p.Stock, p.Image, nullableID(p.CategoryID), nullableID(p.OwnerID),
+		p.SupermarketID, p.Barcode, p.Unit, p.UnitPrice, nullableID(p.ItemID), p.ID,
+	).Scan(&p.LastUpdated)
+	if err != nil {
+		return err
+	}
+
+	if PriceChanged(oldPrice, p.Price) || PriceChanged(oldUnitPrice.Float64, p.UnitPrice) {
+		return recordPrice(tx, p)
+	}
+	return nil
+}
+
+func (pg *Postgres) DeleteProduct(id int) error {
+	result, err := pg.db.Exec(`DELETE FROM products WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (pg *Postgres) ProductHistory(productID int, from, to time.Time) ([]models.PricePoint, error) {
+	var exists bool
+	err := pg.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, productID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := pg.db.Query(`
+		SELECT price, unit_price, recorded_at
+		FROM price_history
+		WHERE product_id = $1 AND recorded_at >= $2 AND recorded_at < $3
+		ORDER BY recorded_at, id
+	`, productID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.PricePoint{}
+	for rows.Next() {
+		var pt models.PricePoint
+		var unitPrice sql.NullFloat64
+		if err := rows.Scan(&pt.Price, &unitPrice, &pt.RecordedAt); err != nil {
+			return nil, err
+		}
+		pt.UnitPrice = unitPrice.Float64
+		history = append(history, pt)
+	}
+	return history, rows.Err()
+}
+
+func (pg *Postgres) BarcodeHistory(barcode string, from, to time.Time) ([]models.PriceSeries, error) {
+	rows, err := pg.db.Query(`
+		SELECT h.supermarket_id, s.name, h.price, h.unit_price, h.recorded_at
+		FROM price_history h
+		LEFT JOIN supermarkets s ON h.supermarket_id = s.id
+		WHERE h.barcode = $1 AND h.supermarket_id IS NOT NULL
+		  AND h.recorded_at >= $2 AND h.recorded_at < $3
+		ORDER BY h.supermarket_id, h.recorded_at, h.id
+	`, barcode, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []models.PriceSeries{}
+	for rows.Next() {
+		var sid int
+		var name sql.NullString
+		var pt models.PricePoint
+		var unitPrice sql.NullFloat64
+		if err := rows.Scan(&sid, &name, &pt.Price, &unitPrice, &pt.RecordedAt); err != nil {
+			return nil, err
+		}
+		pt.UnitPrice = unitPrice.Float64
+
+		last := len(series) - 1
+		if last < 0 || series[last].SupermarketID != sid {
+			series = append(series, models.PriceSeries{
+				SupermarketID:   sid,
+				SupermarketName: name.String,
+			})
+			last++
+		}
+		series[last].History = append(series[last].History, pt)
+	}
+	return series, rows.Err()
+}
+
+func (pg *Postgres) OffersByBarcode(barcode string) ([]models.BarcodeOffer, error) {
+	rows, err := pg.db.Query(`
+		SELECT p.id, COALESCE(c.name, p.name), p.price, p.unit_price, COALESCE(c.unit, p.unit), p.supermarket_id, s.name, p.last_updated
+		FROM products p
+		LEFT JOIN catalog_items c ON p.item_id = c.id
+		LEFT JOIN supermarkets s ON p.supermarket_id = s.id
+		WHERE p.barcode = $1
+		ORDER BY p.unit_price IS NULL, p.unit_price ASC, p.price ASC
+	`, barcode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offers := []models.BarcodeOffer{}
+	for rows.Next() {
+		var o models.BarcodeOffer
+		var unitPrice sql.NullFloat64
+		var unit sql.NullString
+		var supermarketID sql.NullInt64
+		var supermarketName sql.NullString
+		var lastUpdated sql.NullTime
+
+		if err := rows.Scan(&o.ProductID, &o.Name, &o.Price, &unitPrice, &unit, &supermarketID, &supermarketName, &lastUpdated); err != nil {
+			return nil, err
+		}
+
+		if unitPrice.Valid {
+			up := unitPrice.Float64
+			o.UnitPrice = &up
+		}
+		o.Unit = unit.String
+		if supermarketID.Valid {
+			sid := int(supermarketID.Int64)
+			o.SupermarketID = &sid
+		}
+		o.SupermarketName = supermarketName.String
+		if lastUpdated.Valid {
+			lu := lastUpdated.Time.UTC().Format("2006-01-02T15:04:05Z")
+			o.LastUpdated = &lu
+		}
+		offers = append(offers, o)
+	}
+	return offers, rows.Err()
+}
+
+// CheapestOffers resolves all barcodes in a single round trip regardless of
+// how many are asked for.
+func (pg *Postgres) CheapestOffers(barcodes []string) (map[string]map[int]float64, error) {
+	prices := map[string]map[int]float64{}
+	for _, bc := range barcodes {
+		prices[bc] = map[int]float64{}
+	}
+
+	rows, err := pg.db.Query(`
+		SELECT barcode, supermarket_id, MIN(COALESCE(unit_price, price))
+		FROM products
+		WHERE barcode = ANY($1) AND supermarket_id IS NOT NULL
+		GROUP BY barcode, supermarket_id
+	`, pq.Array(barcodes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var barcode string
+		var sid int
+		var price sql.NullFloat64
+		if err := rows.Scan(&barcode, &sid, &price); err != nil {
+			return nil, err
+		}
+		if price.Valid {
+			prices[barcode][sid] = price.Float64
+		}
+	}
+	return prices, rows.Err()
+}
+
+func (pg *Postgres) CheapestSubstitutes(barcodes []string) (map[string]map[int]models.Substitute, error) {
+	rows, err := pg.db.Query(`
+		WITH wanted AS (
+			SELECT DISTINCT ON (barcode) barcode, category_id, unit
+			FROM products
+			WHERE barcode = ANY($1) AND category_id IS NOT NULL
+			ORDER BY barcode, last_updated DESC NULLS LAST
+		)
+		SELECT DISTINCT ON (w.barcode, p.supermarket_id)
+			w.barcode, p.supermarket_id, p.id, p.name, p.barcode, COALESCE(p.unit_price, p.price)
+		FROM wanted w
+		JOIN products p
+		  ON p.category_id = w.category_id
+		 AND p.unit IS NOT DISTINCT FROM w.unit
+		 AND p.barcode IS DISTINCT FROM w.barcode
+		WHERE p.supermarket_id IS NOT NULL
+		ORDER BY w.barcode, p.supermarket_id, COALESCE(p.unit_price, p.price), p.id
+	`, pq.Array(barcodes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := map[string]map[int]models.Substitute{}
+	for rows.Next() {
+		var sub models.Substitute
+		var sid int
+		var barcode sql.NullString
+		if err := rows.Scan(&sub.ForBarcode, &sid, &sub.ProductID, &sub.Name, &barcode, &sub.Price); err != nil {
+			return nil, err
+		}
+		sub.Barcode = barcode.String
+		if subs[sub.ForBarcode] == nil {
+			subs[sub.ForBarcode] = map[int]models.Substitute{}
+		}
+		subs[sub.ForBarcode][sid] = sub
+	}
+	return subs, rows.Err()
+}
+
+// recordPrice appends the product's current price to its timeline.
+func recordPrice(tx *sql.Tx, p *models.Product) error {
+	_, err := tx.Exec(`
+		INSERT INTO price_history (product_id, supermarket_id, barcode, price, unit_price, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, p.ID, nullableID(p.SupermarketID), p.Barcode, p.Price, p.UnitPrice, p.LastUpdated)
+	return err
+}
+
+// PriceChanged compares two prices at the cent precision they are stored with.
+func PriceChanged(before, after float64) bool {
+	return math.Round(before*100) != math.Round(after*100)
+}
+
+func scanProducts(rows *sql.Rows) ([]models.Product, error) {
+	products := []models.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// scanProduct reads one row selected with productColumns, mapping NULLs to
+// the zero values models.Product uses. Columns selected after
+// productColumns are scanned into extra.
+func scanProduct(row rowScanner, extra ...interface{}) (models.Product, error) {
+	var p models.Product
+	var image, barcode, unit sql.NullString
+	var unitPrice sql.NullFloat64
+	var lastUpdated, createdAt sql.NullTime
+	var categoryID, ownerID, supermarketID, itemID sql.NullInt64
+
+	dest := []interface{}{
+		&p.ID, &p.Name, &p.Price, &p.Stock,
+		&image, &categoryID, &ownerID, &supermarketID,
+		&barcode, &unit, &unitPrice, &lastUpdated, &createdAt, &itemID,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return p, err
+	}
+
+	p.Image = image.String
+	p.Barcode = barcode.String
+	p.Unit = unit.String
+	if unitPrice.Valid {
+		p.UnitPrice = unitPrice.Float64
+	}
+	if lastUpdated.Valid {
+		p.LastUpdated = lastUpdated.Time
+	}
+	if categoryID.Valid {
+		p.CategoryID = int(categoryID.Int64)
+	}
+	if ownerID.Valid {
+		p.OwnerID = int(ownerID.Int64)
+	}
+	if supermarketID.Valid {
+		p.SupermarketID = int(supermarketID.Int64)
+	}
+	if itemID.Valid {
+		p.ItemID = int(itemID.Int64)
+	}
+	return p, nil
+}
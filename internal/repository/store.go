@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"errors"
+	"supermarket-catalogue/internal/models"
+	"time"
+)
+
+// ErrNotFound is returned by stores when the requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrConflict is returned when a write would break a uniqueness rule, such as
+// two categories of the same name under one parent.
+var ErrConflict = errors.New("conflicts with an existing record")
+
+// ErrTokenReused is returned when a refresh token is presented a second
+// time. Its whole family has been revoked by then.
+var ErrTokenReused = errors.New("refresh token reused")
+
+// ProductSortKeys lists the values accepted in ProductFilter.Sort.
+var ProductSortKeys = []string{"price", "unit_price", "name", "last_updated"}
+
+// ProductFilter narrows a product listing. Zero values leave a field unfiltered.
+type ProductFilter struct {
+	Name          string
+	SupermarketID int
+	CategoryID    int
+	// CategoryIDs matches products filed under any of the categories.
+	CategoryIDs []int
+	Barcode     string
+	// Barcodes matches products with any of the barcodes.
+	Barcodes []string
+	OwnerID  int
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  bool
+	// Sort is one of ProductSortKeys; empty sorts by id. Desc reverses either.
+	Sort string
+	Desc bool
+}
+
+// Page selects a window of a listing.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+type ProductStore interface {
+	ListProducts(f ProductFilter, page Page) ([]models.Product, int, error)
+	// EachProduct calls fn for every product matching f, in the listing's
+	// order, without loading them all at once. It stops at fn's first error.
+	EachProduct(f ProductFilter, fn func(models.Product) error) error
+	SearchProducts(q string, page Page) ([]models.ProductSearchResult, int, error)
+	GetProduct(id int) (*models.Product, error)
+	// CreateProduct and UpdateProduct record the product's price in its
+	// history when it is new or has changed.
+	CreateProduct(p *models.Product) error
+	UpdateProduct(p *models.Product) error
+	// SaveProducts creates the products without an ID and updates the
+	// others, writing either all of them or, on error, none.
+	SaveProducts(products []*models.Product) error
+	DeleteProduct(id int) error
+
+	ProductHistory(productID int, from, to time.Time) ([]models.PricePoint, error)
+	BarcodeHistory(barcode string, from, to time.Time) ([]models.PriceSeries, error)
+	OffersByBarcode(barcode string) ([]models.BarcodeOffer, error)
+	// CheapestOffers maps each barcode to the lowest price per supermarket.
+	CheapestOffers(barcodes []string) (map[string]map[int]float64, error)
+	// CheapestSubstitutes maps each barcode to the cheapest other product per
+	// supermarket sharing the barcode's category and unit.
+	CheapestSubstitutes(barcodes []string) (map[string]map[int]models.Substitute, error)
+}
+
+// CatalogStore manages the catalog items products are offers of. Creating or
+// updating a product with a barcode creates its item on first sight.
+//
+// Product listings, search and exports still read the name, unit and
+// category stored on each product, which a product write sets for that
+// listing alone. UpdateCatalogItem copies them onto every offer of the item,
+// so a corrected item is listed the same way everywhere.
+type CatalogStore interface {
+	// GetCatalogItem returns the item for barcode with all of its offers,
+	// cheapest first.
+	GetCatalogItem(barcode string) (*models.CatalogItem, error)
+	// UpdateCatalogItem saves item and applies its name, unit and category
+	// to each of its offers.
+	UpdateCatalogItem(item *models.CatalogItem) error
+}
+
+type SupermarketStore interface {
+	ListSupermarkets() ([]models.Supermarket, error)
+	GetSupermarket(id int) (*models.Supermarket, error)
+	CreateSupermarket(s *models.Supermarket) error
+	UpdateSupermarket(s *models.Supermarket) error
+	DeleteSupermarket(id int) error
+	SupermarketStats() ([]models.SupermarketStats, error)
+
+	// ManagesSupermarket reports whether the user owns the supermarket or
+	// is assigned to it.
+	ManagesSupermarket(supermarketID, userID int) (bool, error)
+	// ListSupermarketManagers returns the users assigned to the supermarket;
+	// its owner is not included.
+	ListSupermarketManagers(supermarketID int) ([]models.User, error)
+	// ListManagedSupermarkets returns the supermarkets the user is assigned
+	// to; those it owns are not included.
+	ListManagedSupermarkets(userID int) ([]models.Supermarket, error)
+	// AssignManager is a no-op when the user is already assigned.
+	AssignManager(supermarketID, userID int) error
+	UnassignManager(supermarketID, userID int) error
+}
+
+type CategoryStore interface {
+	ListCategories() ([]models.Category, error)
+	GetCategory(id int) (*models.Category, error)
+	CategoryExists(id int) (bool, error)
+	CreateCategory(c *models.Category) error
+	UpdateCategory(c *models.Category) error
+	DeleteCategory(id int) error
+	// CategorySubtree returns id followed by the ids of all its descendants.
+	CategorySubtree(id int) ([]int, error)
+}
+
+type UserStore interface {
+	// CreateUser hashes user.Password before storing it.
+	CreateUser(user *models.User) error
+	GetUserByEmail(email string) (*models.User, error)
+	GetUserByID(id int) (*models.User, error)
+	ListUsers() ([]models.User, error)
+	// UpdatePassword hashes password before storing it.
+	UpdatePassword(id int, password string) error
+	SetEmailVerified(id int) error
+	// UpdateProfile sets the name and email address. A new address starts
+	// out unverified.
+	UpdateProfile(id int, name, email string) error
+	UpdateUserRole(id int, role string) error
+	SetUserDisabled(id int, disabled bool) error
+	// DeleteUser removes the account and clears it as owner of catalogue
+	// records.
+	DeleteUser(id int) error
+	// EraseUser removes the personal data of the account, its second
+	// factor, linked identities, sessions and manager assignments, and
+	// disables it. The account stays as owner of its catalogue records. It
+	// returns ErrNotFound for unknown or already erased accounts.
+	EraseUser(id int) error
+}
+
+// TokenStore keeps refresh tokens and the access token IDs revoked before
+// they expire.
+type TokenStore interface {
+	CreateRefreshToken(t *models.RefreshToken) error
+	// ConsumeRefreshToken marks the token with the given hash as used and
+	// returns it. Presenting a used token revokes its family and returns
+	// ErrTokenReused.
+	ConsumeRefreshToken(hash string) (*models.RefreshToken, error)
+	RevokeFamily(familyID string) error
+	// RevokeUserTokens revokes every refresh token family of the user, and
+	// with them the access tokens issued from those families.
+	RevokeUserTokens(userID int) error
+	RevokeAccessToken(jti string, userID int, expiresAt time.Time) error
+	// ConsumeTokenID records a single-use token ID as spent. It reports
+	// false if the ID was already spent.
+	ConsumeTokenID(jti string, userID int, expiresAt time.Time) (bool, error)
+	// IsRevoked reports whether the access token ID or its session has been
+	// revoked.
+	IsRevoked(jti, familyID string) (bool, error)
+}
+
+// TwoFactorStore keeps the TOTP secrets and recovery codes of accounts.
+// Recovery codes are stored as HashToken digests.
+type TwoFactorStore interface {
+	// GetTOTP returns ErrNotFound when the user has not started setup.
+	GetTOTP(userID int) (*models.TOTP, error)
+	// SetPendingTOTP stores a secret awaiting confirmation, replacing any
+	// earlier one.
+	SetPendingTOTP(userID int, secret string) error
+	// EnableTOTP confirms the pending secret and replaces the recovery
+	// codes.
+	EnableTOTP(userID int, recoveryCodeHashes []string) error
+	// DisableTOTP removes the secret and the recovery codes.
+	DisableTOTP(userID int) error
+	// UseTOTPStep records step as the last accepted one. It reports false,
+	// changing nothing, when step is not after the last accepted step.
+	UseTOTPStep(userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID int, hashes []string) error
+	// ConsumeRecoveryCode marks an unused code as used. It reports false
+	// when there is no such unused code.
+	ConsumeRecoveryCode(userID int, hash string) (bool, error)
+}
+
+// APIKeyStore keeps the API keys issued to machine clients, identified by
+// the HashToken digest of the key.
+type APIKeyStore interface {
+	CreateAPIKey(k *models.APIKey) error
+	GetAPIKeyByHash(hash string) (*models.APIKey, error)
+	ListAPIKeys() ([]models.APIKey, error)
+	// RevokeAPIKey returns ErrNotFound for an unknown or already revoked key.
+	RevokeAPIKey(id int) error
+	// TouchAPIKey records a use of the key. Stores may skip the write when
+	// the last recorded use is recent.
+	TouchAPIKey(id int, at time.Time) error
+}
+
+// IdentityStore links accounts to their identities at OpenID Connect
+// providers, so a login keeps finding the same account when the email
+// address at the provider changes.
+type IdentityStore interface {
+	// GetIdentity returns ErrNotFound when subject at issuer is not linked.
+	GetIdentity(issuer, subject string) (*models.Identity, error)
+	ListIdentities(userID int) ([]models.Identity, error)
+	LinkIdentity(identity *models.Identity) error
+}
+
+// LoginAttemptStore counts consecutive failed logins per throttle key.
+type LoginAttemptStore interface {
+	// GetLoginAttempts returns ErrNotFound when key has no failures on record.
+	GetLoginAttempts(key string) (*models.LoginAttempts, error)
+	// RecordLoginFailure adds a failure at the given time and returns the
+	// new state. A key whose last failure is older than window starts
+	// over, lock included.
+	RecordLoginFailure(key string, at time.Time, window time.Duration) (*models.LoginAttempts, error)
+	LockLogin(key string, until time.Time) error
+	ClearLoginAttempts(key string) error
+}
+
+// Stores bundles the stores the HTTP handlers depend on.
+type Stores struct {
+	Products     ProductStore
+	Catalog      CatalogStore
+	Supermarkets SupermarketStore
+	Categories   CategoryStore
+	Users        UserStore
+	Tokens       TokenStore
+	Logins       LoginAttemptStore
+	TwoFactor    TwoFactorStore
+	APIKeys      APIKeyStore
+	Identities   IdentityStore
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"database/sql"
+	"supermarket-catalogue/internal/models"
+)
+
+func (pg *Postgres) ListSupermarkets() ([]models.Supermarket, error) {
+	rows, err := pg.db.Query(`
+		SELECT id, name, address, owner_id, created_at
+		FROM supermarkets
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.Supermarket{}
+	for rows.Next() {
+		s, err := scanSupermarket(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *s)
+	}
+	return items, rows.Err()
+}
+
+func (pg *Postgres) GetSupermarket(id int) (*models.Supermarket, error) {
+	s, err := scanSupermarket(pg.db.QueryRow(`
+		SELECT id, name, address, owner_id, created_at
+		FROM supermarkets
+		WHERE id = $1
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return s, err
+}
+
+func (pg *Postgres) CreateSupermarket(s *models.Supermarket) error {
+	return pg.db.QueryRow(`
+		INSERT INTO supermarkets (name, address, owner_id)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, s.Name, s.Address, nullableID(s.OwnerID)).Scan(&s.ID, &s.CreatedAt)
+}
+
+func (pg *Postgres) UpdateSupermarket(s *models.Supermarket) error {
+	err := pg.db.QueryRow(`
+		UPDATE supermarkets
+		SET name = $1, address = $2, owner_id = $3
+		WHERE id = $4
+		RETURNING created_at
+	`, s.Name, s.Address, nullableID(s.OwnerID), s.ID).Scan(&s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (pg *Postgres) DeleteSupermarket(id int) error {
+	result, err := pg.db.Exec(`DELETE FROM supermarkets WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (pg *Postgres) SupermarketStats() ([]models.SupermarketStats, error) {
+	rows, err := pg.db.Query(`
+		SELECT s.id, s.name, COUNT(p.id), AVG(p.price), MIN(p.price), MAX(p.price)
+		FROM supermarkets s
+		LEFT JOIN products p ON p.supermarket_id = s.id
+		GROUP BY s.id, s.name
+		ORDER BY s.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []models.SupermarketStats{}
+	for rows.Next() {
+		var s models.SupermarketStats
+		var avg, min, max sql.NullFloat64
+		if err := rows.Scan(&s.SupermarketID, &s.SupermarketName, &s.ProductCount, &avg, &min, &max); err != nil {
+			return nil, err
+		}
+		s.AvgPrice = avg.Float64
+		s.MinPrice = min.Float64
+		s.MaxPrice = max.Float64
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func scanSupermarket(row rowScanner) (*models.Supermarket, error) {
+	var s models.Supermarket
+	var addr sql.NullString
+	var ownerID sql.NullInt64
+	var createdAt sql.NullTime
+
+	if err := row.Scan(&s.ID, &s.Name, &addr, &ownerID, &createdAt); err != nil {
+		return nil, err
+	}
+
+	s.Address = addr.String
+	if ownerID.Valid {
+		s.OwnerID = int(ownerID.Int64)
+	}
+	if createdAt.Valid {
+		s.CreatedAt = createdAt.Time
+	}
+	return &s, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"database/sql"
+	"supermarket-catalogue/internal/models"
+	"time"
+)
+
+const refreshTokenColumns = `id, token_hash, user_id, family_id, mfa, expires_at, used_at, revoked_at, created_at`
+
+func scanRefreshToken(row rowScanner) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.TokenHash, &t.UserID, &t.FamilyID, &t.MFA, &t.ExpiresAt, &usedAt, &revokedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		t.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return &t, nil
+}
+
+func (pg *Postgres) CreateRefreshToken(t *models.RefreshToken) error {
+	return pg.db.QueryRow(`
+		INSERT INTO refresh_tokens (token_hash, user_id, family_id, mfa, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, t.TokenHash, t.UserID, t.FamilyID, t.MFA, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+}
+
+func (pg *Postgres) ConsumeRefreshToken(hash string) (*models.RefreshToken, error) {
+	t, err := scanRefreshToken(pg.db.QueryRow(`
+		UPDATE refresh_tokens
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL
+		RETURNING `+refreshTokenColumns, hash))
+	if err == nil {
+		return t, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// Either the token never existed or it was already rotated, in which
+	// case someone is replaying it and the family can no longer be trusted.
+	var familyID string
+	err = pg.db.QueryRow(`SELECT family_id FROM refresh_tokens WHERE token_hash = $1`, hash).Scan(&familyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := pg.RevokeFamily(familyID); err != nil {
+		return nil, err
+	}
+	return nil, ErrTokenReused
+}
+
+func (pg *Postgres) RevokeFamily(familyID string) error {
+	_, err := pg.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	return err
+}
+
+func (pg *Postgres) RevokeUserTokens(userID int) error {
+	_, err := pg.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return err
+}
+
+func (pg *Postgres) RevokeAccessToken(jti string, userID int, expiresAt time.Time) error {
+	return inTx(pg.db, func(tx *sql.Tx) error {
+		// Entries past their expiry protect nothing; drop them while here.
+		if _, err := tx.Exec(`DELETE FROM revoked_tokens WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO revoked_tokens (jti, user_id, expires_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (jti) DO NOTHING
+		`, jti, nullableID(userID), expiresAt)
+		return err
+	})
+}
+
+func (pg *Postgres) ConsumeTokenID(jti string, userID int, expiresAt time.Time) (bool, error) {
+	result, err := pg.db.Exec(`
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, nullableID(userID), expiresAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (pg *Postgres) IsRevoked(jti, familyID string) (bool, error) {
+	var revoked bool
+	err := pg.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+			OR EXISTS (SELECT 1 FROM refresh_tokens WHERE family_id = $2 AND revoked_at IS NOT NULL)
+	`, jti, familyID).Scan(&revoked)
+	return revoked, err
+}
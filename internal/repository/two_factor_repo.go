@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"supermarket-catalogue/internal/models"
+)
+
+func (pg *Postgres) GetTOTP(userID int) (*models.TOTP, error) {
+	var t models.TOTP
+	var secret sql.NullString
+	err := pg.db.QueryRow(`
+		SELECT totp_secret, totp_enabled_at IS NOT NULL, totp_last_step,
+			(SELECT COUNT(*) FROM recovery_codes WHERE user_id = users.id AND used_at IS NULL)
+		FROM users
+		WHERE id = $1
+	`, userID).Scan(&secret, &t.Enabled, &t.LastStep, &t.RecoveryCodesLeft)
+	if err == sql.ErrNoRows || err == nil && !secret.Valid {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.Secret = secret.String
+	return &t, nil
+}
+
+func (pg *Postgres) SetPendingTOTP(userID int, secret string) error {
+	return execOne(pg.db, `
+		UPDATE users SET totp_secret = $1, totp_enabled_at = NULL, totp_last_step = 0
+		WHERE id = $2
+	`, secret, userID)
+}
+
+func (pg *Postgres) EnableTOTP(userID int, recoveryCodeHashes []string) error {
+	return inTx(pg.db, func(tx *sql.Tx) error {
+		if err := execOne(tx, `
+			UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND totp_secret IS NOT NULL
+		`, userID); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, recoveryCodeHashes)
+	})
+}
+
+func (pg *Postgres) DisableTOTP(userID int) error {
+	return inTx(pg.db, func(tx *sql.Tx) error {
+		if err := execOne(tx, `
+			UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+			WHERE id = $1
+		`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+func (pg *Postgres) UseTOTPStep(userID int, step int64) (bool, error) {
+	result, err := pg.db.Exec(`
+		UPDATE users SET totp_last_step = $1
+		WHERE id = $2 AND totp_last_step < $1
+	`, step, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (pg *Postgres) ReplaceRecoveryCodes(userID int, hashes []string) error {
+	return inTx(pg.db, func(tx *sql.Tx) error {
+		return replaceRecoveryCodes(tx, userID, hashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, hashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pg *Postgres) ConsumeRecoveryCode(userID int, hash string) (bool, error) {
+	result, err := pg.db.Exec(`
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
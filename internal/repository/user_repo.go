@@ -1,62 +1,166 @@
 package repository
 
 import (
+	"database/sql"
 	"supermarket-catalogue/internal/auth"
 	"supermarket-catalogue/internal/models"
 )
 
-func CreateUser(user *models.User) error {
+func (pg *Postgres) CreateUser(user *models.User) error {
 	hashedPassword, err := auth.HashPassword(user.Password)
 	if err != nil {
 		return err
 	}
 
-	query := `INSERT INTO users (name, email, password, role) 
-	          VALUES ($1, $2, $3, $4) RETURNING id, created_at`
-	err = DB.QueryRow(query, user.Name, user.Email, hashedPassword, user.Role).
+	query := `INSERT INTO users (name, email, password, role, email_verified_at)
+	          VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN CURRENT_TIMESTAMP END) RETURNING id, created_at`
+	err = pg.db.QueryRow(query, user.Name, user.Email, hashedPassword, user.Role, user.EmailVerified).
 		Scan(&user.ID, &user.CreatedAt)
 	return err
 }
 
-func GetUserByEmail(email string) (*models.User, error) {
+// userColumns is the select list understood by scanUser. The password hash
+// is only selected where it is needed.
+const userColumns = `id, name, email, role, created_at, email_verified_at IS NOT NULL, disabled_at IS NOT NULL, totp_enabled_at IS NOT NULL, erased_at IS NOT NULL`
+
+func scanUser(row rowScanner, extra ...interface{}) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, name, email, password, role, created_at 
-	          FROM users WHERE email = $1`
-	err := DB.QueryRow(query, email).
-		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.CreatedAt)
-	if err != nil {
+	dest := []interface{}{&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt, &user.EmailVerified, &user.Disabled, &user.TOTPEnabled, &user.Erased}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func GetUserByID(id int) (*models.User, error) {
-	var user models.User
-	query := `SELECT id, name, email, role, created_at 
-	          FROM users WHERE id = $1`
-	err := DB.QueryRow(query, id).
-		Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt)
+func (pg *Postgres) GetUserByEmail(email string) (*models.User, error) {
+	var password string
+	user, err := scanUser(pg.db.QueryRow(`SELECT `+userColumns+`, password FROM users WHERE email = $1`, email), &password)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	user.Password = password
+	return user, nil
 }
 
-func GetAllUsers() ([]models.User, error) {
-	rows, err := DB.Query(`SELECT id, name, email, role, created_at FROM users`)
+func (pg *Postgres) GetUserByID(id int) (*models.User, error) {
+	user, err := scanUser(pg.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return user, err
+}
+
+func (pg *Postgres) ListUsers() ([]models.User, error) {
+	rows, err := pg.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY id`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var users []models.User
+	users := []models.User{}
 	for rows.Next() {
-		var user models.User
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.CreatedAt)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, user)
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (pg *Postgres) UpdatePassword(id int, password string) error {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
 	}
-	return users, nil
+	return execOne(pg.db, `UPDATE users SET password = $1 WHERE id = $2`, hashedPassword, id)
+}
+
+func (pg *Postgres) SetEmailVerified(id int) error {
+	return execOne(pg.db, `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+		WHERE id = $1
+	`, id)
+}
+
+// execOne runs an UPDATE or DELETE of a single record, mapping no affected
+// rows to ErrNotFound.
+func execOne(db execer, query string, args ...interface{}) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (pg *Postgres) UpdateProfile(id int, name, email string) error {
+	return execOne(pg.db, `
+		UPDATE users SET
+			name = $2,
+			email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
+			email = $3
+		WHERE id = $1
+	`, id, name, email)
+}
+
+func (pg *Postgres) UpdateUserRole(id int, role string) error {
+	return execOne(pg.db, `UPDATE users SET role = $1 WHERE id = $2`, role, id)
+}
+
+func (pg *Postgres) SetUserDisabled(id int, disabled bool) error {
+	return execOne(pg.db, `
+		UPDATE users SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, CURRENT_TIMESTAMP) END
+		WHERE id = $2
+	`, disabled, id)
+}
+
+// EraseUser anonymises the account in place: name and email are replaced,
+// the password, two-factor secret and verification are cleared and the
+// account is disabled, so records pointing at it stay valid. Its recovery
+// codes, linked identities, refresh tokens and manager assignments are
+// deleted.
+func (pg *Postgres) EraseUser(id int) error {
+	return inTx(pg.db, func(tx *sql.Tx) error {
+		err := execOne(tx, `
+			UPDATE users SET
+				name = $2,
+				email = 'erased-' || id || '@invalid',
+				password = '',
+				email_verified_at = NULL,
+				disabled_at = COALESCE(disabled_at, CURRENT_TIMESTAMP),
+				totp_secret = NULL,
+				totp_enabled_at = NULL,
+				totp_last_step = 0,
+				erased_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND erased_at IS NULL
+		`, id, models.ErasedUserName)
+		if err != nil {
+			return err
+		}
+		for _, table := range []string{"recovery_codes", "user_identities", "refresh_tokens", "supermarket_managers"} {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE user_id = $1`, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteUser removes the account. Catalogue records it created stay, with
+// their owner cleared.
+func (pg *Postgres) DeleteUser(id int) error {
+	return inTx(pg.db, func(tx *sql.Tx) error {
+		for _, table := range []string{"products", "supermarkets", "categories"} {
+			if _, err := tx.Exec(`UPDATE `+table+` SET owner_id = NULL WHERE owner_id = $1`, id); err != nil {
+				return err
+			}
+		}
+		return execOne(tx, `DELETE FROM users WHERE id = $1`, id)
+	})
 }
@@ -35,7 +35,41 @@ function showSuccess(elementId, message) {
 }
 
 
-async function makeRequest(method, endpoint, data = null) {
+function saveAuth(data) {
+    currentToken = data.token;
+    currentUser = data.user;
+    localStorage.setItem('token', currentToken);
+    localStorage.setItem('refreshToken', data.refresh_token || '');
+    localStorage.setItem('user', JSON.stringify(currentUser));
+}
+
+function clearAuth() {
+    currentToken = null;
+    currentUser = null;
+    localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
+    localStorage.removeItem('user');
+}
+
+// Access tokens are short-lived; trade the refresh token for a new pair.
+async function refreshAuth() {
+    const refreshToken = localStorage.getItem('refreshToken');
+    if (!refreshToken) {
+        return false;
+    }
+    const response = await fetch(API_BASE + '/token/refresh', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    if (!response.ok) {
+        return false;
+    }
+    saveAuth(await response.json());
+    return true;
+}
+
+async function makeRequest(method, endpoint, data = null, retried = false) {
     const options = {
         method: method,
         headers: {
@@ -65,11 +99,11 @@ async function makeRequest(method, endpoint, data = null) {
         }
         
         if (!response.ok) {
-            if (response.status === 401) {
-                currentToken = null;
-                currentUser = null;
-                localStorage.removeItem('token');
-                localStorage.removeItem('user');
+            if (response.status === 401 && currentToken) {
+                if (!retried && await refreshAuth()) {
+                    return makeRequest(method, endpoint, data, true);
+                }
+                clearAuth();
                 updateAuthStatus();
             }
             throw new Error(responseData.error || responseData.message || `HTTP ${response.status}`);
@@ -86,8 +120,7 @@ function register() {
     const user = {
         name: document.getElementById('regName').value,
         email: document.getElementById('regEmail').value,
-        password: document.getElementById('regPassword').value,
-        role: document.getElementById('regRole').value || 'user'
+        password: document.getElementById('regPassword').value
     };
 
     if (!user.name || !user.email || !user.password) {
@@ -99,10 +132,7 @@ function register() {
     
     makeRequest('POST', '/register', user)
         .then(data => {
-            currentToken = data.token;
-            currentUser = data.user;
-            localStorage.setItem('token', currentToken);
-            localStorage.setItem('user', JSON.stringify(currentUser));
+            saveAuth(data);
             updateAuthStatus();
             showSuccess('registerResult', `Registered successfully as ${user.name}`);
         })
@@ -122,12 +152,21 @@ function login() {
 
     showLoading('loginResult');
     
-    makeRequest('POST', '/login', credentials)
+    completeLogin(makeRequest('POST', '/login', credentials));
+}
+
+// completeLogin asks for the second factor when the account needs one and
+// then stores the session.
+function completeLogin(request) {
+    request
+    .then(data => {
+        if (!data.mfa_required) return data;
+        const code = prompt('Enter the code from your authenticator app, or a recovery code:');
+        if (!code) throw new Error('Login cancelled');
+        return makeRequest('POST', '/login/2fa', { challenge: data.challenge, code: code.trim() });
+    })
     .then(data => {
-        currentToken = data.token;
-        currentUser = data.user;
-        localStorage.setItem('token', currentToken);
-        localStorage.setItem('user', JSON.stringify(currentUser));
+        saveAuth(data);
         loadAuthFromStorage();
         updateAuthStatus();
 
@@ -140,21 +179,74 @@ function login() {
         getAllProducts(); 
     })
     .catch(error => showError('loginResult', error));
+}
 
+function loginWithSSO() {
+    window.location = '/login/oidc';
+}
+
+// Single sign-on comes back as ?sso_token=, to be exchanged for a session,
+// or ?sso_error=.
+function handleSSORedirect() {
+    const params = new URLSearchParams(window.location.search);
+    const token = params.get('sso_token');
+    const error = params.get('sso_error');
+    if (!token && !error) return;
+    window.history.replaceState({}, '', window.location.pathname);
+
+    if (error) {
+        alert('Single sign-on failed: ' + error);
+        return;
+    }
+    showTab('auth');
+    completeLogin(makeRequest('POST', '/login/oidc/token', { token: token }));
 }
 
 function logout() {
-    currentToken = null;
-    currentUser = null;
+    if (currentToken) {
+        makeRequest('POST', '/logout').catch(() => {});
+    }
+    clearAuth();
     currentEditProductId = null;
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
     updateAuthStatus();
     hideCreateForm();
     alert('Logged out successfully');
     getAllProducts(); 
 }
 
+function forgotPassword() {
+    const email = document.getElementById('loginEmail').value || prompt('Email address:');
+    if (!email) return;
+    makeRequest('POST', '/password/forgot', { email: email })
+        .then(() => showSuccess('loginResult', 'If the address is registered, a reset link is on its way.'))
+        .catch(error => showError('loginResult', error));
+}
+
+// Links in account emails come back as ?verify_token= or ?reset_token=.
+async function handleEmailLinks() {
+    const params = new URLSearchParams(window.location.search);
+    const verifyToken = params.get('verify_token');
+    const resetToken = params.get('reset_token');
+    if (!verifyToken && !resetToken) return;
+    window.history.replaceState({}, '', window.location.pathname);
+
+    try {
+        if (verifyToken) {
+            await makeRequest('POST', '/email/verify', { token: verifyToken });
+            alert('Email address confirmed.');
+        } else {
+            const password = prompt('Choose a new password (at least 8 characters):');
+            if (!password) return;
+            await makeRequest('POST', '/password/reset', { token: resetToken, password: password });
+            clearAuth();
+            updateAuthStatus();
+            alert('Password changed. Please log in again.');
+        }
+    } catch (error) {
+        alert('Error: ' + error.message);
+    }
+}
+
 function updateAuthStatus() {
     const authStatus = document.getElementById('authStatus');
     if (!authStatus) return;
@@ -301,7 +393,7 @@ function createProduct(event) {
         stock: parseInt(document.getElementById('prodStock').value) || 0,
         barcode: document.getElementById('prodBarcode').value || "",
         image: document.getElementById('prodImage').value || "",
-        category_id: parseInt(document.getElementById('prodCategory').value) || 1,
+        category_id: parseInt(document.getElementById('prodCategory').value) || 0,
         supermarket_id: parseInt(document.getElementById('prodSupermarket').value) || 1,
         unit: "pcs", 
         unit_price: parseFloat(document.getElementById('prodPrice').value) || 0
@@ -367,7 +459,7 @@ async function updateProduct() {
         stock: parseInt(document.getElementById('prodStock').value),
         barcode: document.getElementById('prodBarcode').value,
         image: document.getElementById('prodImage').value,
-        category_id: parseInt(document.getElementById('prodCategory').value) || 1,
+        category_id: parseInt(document.getElementById('prodCategory').value) || 0,
         supermarket_id: parseInt(document.getElementById('prodSupermarket').value) || 1,
         unit_price: parseFloat(document.getElementById('prodPrice').value)
     };
@@ -614,6 +706,8 @@ function healthCheck() {
 
 document.addEventListener('DOMContentLoaded', function() {
     loadAuthFromStorage();
+    handleEmailLinks();
+    handleSSORedirect();
     updateUIBasedOnAuth();
     getAllProducts();
 });
\ No newline at end of file